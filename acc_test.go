@@ -1,10 +1,13 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"os"
 	"testing"
 
+	"github.com/flawless/terraform-provider-aidbox/internal/client"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/acctest"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
@@ -41,6 +44,27 @@ func TestAccAidboxUser(t *testing.T) {
 					testAccCheckAidboxUserAttributes("aidbox_user.test", givenName, familyName),
 				),
 			},
+			{
+				ResourceName:      "aidbox_user.test",
+				ImportState:       true,
+				ImportStateVerify: true,
+				// The password is write-only and never read back
+				ImportStateVerifyIgnore: []string{"password_version"},
+			},
+			{
+				// A change made outside Terraform must show up in the plan
+				PreConfig: func() {
+					testAccUpdateAidboxResource(t, "User", resourceName, map[string]interface{}{
+						"name": map[string]interface{}{
+							"givenName":  givenName,
+							"familyName": "Changed",
+						},
+					})
+				},
+				Config:             testAccAidboxUserConfig(resourceName, givenName, familyName),
+				PlanOnly:           true,
+				ExpectNonEmptyPlan: true,
+			},
 		},
 	})
 }
@@ -75,6 +99,11 @@ func TestAccAidboxRole(t *testing.T) {
 					testAccCheckAidboxRoleAttributes("aidbox_role.test", roleName, userName),
 				),
 			},
+			{
+				ResourceName:      "aidbox_role.test",
+				ImportState:       true,
+				ImportStateVerify: true,
+			},
 		},
 	})
 }
@@ -108,10 +137,154 @@ func TestAccAidboxAccessPolicy(t *testing.T) {
 					testAccCheckAidboxAccessPolicyAttributes("aidbox_access_policy.test", engine),
 				),
 			},
+			{
+				ResourceName:      "aidbox_access_policy.test",
+				ImportState:       true,
+				ImportStateVerify: true,
+			},
+		},
+	})
+}
+
+func TestAccAidboxClient(t *testing.T) {
+	resourceName := acctest.RandString(8)
+
+	resource.Test(t, resource.TestCase{
+		PreCheck: func() {
+			if v := os.Getenv("AIDBOX_URL"); v == "" {
+				t.Fatal("AIDBOX_URL must be set for acceptance tests")
+			}
+			if v := os.Getenv("AIDBOX_CLIENT_ID"); v == "" {
+				t.Fatal("AIDBOX_CLIENT_ID must be set for acceptance tests")
+			}
+			if v := os.Getenv("AIDBOX_CLIENT_SECRET"); v == "" {
+				t.Fatal("AIDBOX_CLIENT_SECRET must be set for acceptance tests")
+			}
+		},
+		ProviderFactories: map[string]func() (*schema.Provider, error){
+			"aidbox": func() (*schema.Provider, error) {
+				return Provider(), nil
+			},
+		},
+		Steps: []resource.TestStep{
+			{
+				Config: testAccAidboxClientConfig(resourceName, true),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttrSet("aidbox_client.test", "secret"),
+					resource.TestCheckResourceAttr("aidbox_client.test", "grant_types.#", "2"),
+					resource.TestCheckResourceAttr("aidbox_client.test", "authorization_code.0.redirect_uri", "https://app.example.com/callback"),
+					resource.TestCheckResourceAttr("aidbox_client.test", "client_credentials.0.access_token_expiration", "3600"),
+				),
+			},
+			{
+				ResourceName:      "aidbox_client.test",
+				ImportState:       true,
+				ImportStateVerify: true,
+				// The secret is never read back
+				ImportStateVerifyIgnore: []string{"secret", "generate_secret"},
+				// Keep the imported state so the next step updates from it
+				ImportStatePersist: true,
+			},
+			{
+				Config: testAccAidboxClientConfig(resourceName, false),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("aidbox_client.test", "first_party", "false"),
+					testAccCheckAidboxClientSecretKept("aidbox_client.test"),
+				),
+			},
 		},
 	})
 }
 
+func TestAccAidboxResource(t *testing.T) {
+	resourceName := acctest.RandString(8)
+	orgName := "Test Organization"
+
+	resource.Test(t, resource.TestCase{
+		PreCheck: func() {
+			if v := os.Getenv("AIDBOX_URL"); v == "" {
+				t.Fatal("AIDBOX_URL must be set for acceptance tests")
+			}
+			if v := os.Getenv("AIDBOX_CLIENT_ID"); v == "" {
+				t.Fatal("AIDBOX_CLIENT_ID must be set for acceptance tests")
+			}
+			if v := os.Getenv("AIDBOX_CLIENT_SECRET"); v == "" {
+				t.Fatal("AIDBOX_CLIENT_SECRET must be set for acceptance tests")
+			}
+		},
+		ProviderFactories: map[string]func() (*schema.Provider, error){
+			"aidbox": func() (*schema.Provider, error) {
+				return Provider(), nil
+			},
+		},
+		Steps: []resource.TestStep{
+			{
+				Config: testAccAidboxResourceConfig(resourceName, orgName),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("aidbox_resource.test", "id", resourceName),
+					resource.TestCheckResourceAttr("aidbox_resource.test", "resource_type", "Organization"),
+				),
+			},
+			{
+				ResourceName:        "aidbox_resource.test",
+				ImportState:         true,
+				ImportStateVerify:   true,
+				ImportStateIdPrefix: "Organization/",
+			},
+		},
+	})
+}
+
+// testAccUpdateAidboxResource overwrites a resource directly in Aidbox to simulate drift
+func testAccUpdateAidboxResource(t *testing.T, resourceType, id string, fields map[string]interface{}) {
+	c := client.NewClient(&client.Config{
+		URL:          os.Getenv("AIDBOX_URL"),
+		ClientID:     os.Getenv("AIDBOX_CLIENT_ID"),
+		ClientSecret: os.Getenv("AIDBOX_CLIENT_SECRET"),
+	})
+
+	fields["resourceType"] = resourceType
+	fields["id"] = id
+	body, err := json.Marshal(fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.UpdateResource(context.Background(), resourceType, id, string(body), ""); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// testAccCheckAidboxClientSecretKept checks that the Client in Aidbox still
+// has the secret from the state
+func testAccCheckAidboxClientSecretKept(n string) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		rs, ok := s.RootModule().Resources[n]
+		if !ok {
+			return fmt.Errorf("Not found: %s", n)
+		}
+
+		c := client.NewClient(&client.Config{
+			URL:          os.Getenv("AIDBOX_URL"),
+			ClientID:     os.Getenv("AIDBOX_CLIENT_ID"),
+			ClientSecret: os.Getenv("AIDBOX_CLIENT_SECRET"),
+		})
+		body, err := c.GetResource(context.Background(), "Client", rs.Primary.ID)
+		if err != nil {
+			return err
+		}
+		var stored struct {
+			Secret string `json:"secret"`
+		}
+		if err := json.Unmarshal([]byte(body), &stored); err != nil {
+			return err
+		}
+		if stored.Secret == "" || stored.Secret != rs.Primary.Attributes["secret"] {
+			return fmt.Errorf("Client %s lost its secret on update", rs.Primary.ID)
+		}
+		return nil
+	}
+}
+
 func testAccCheckAidboxUserExists(n string) resource.TestCheckFunc {
 	return func(s *terraform.State) error {
 		rs, ok := s.RootModule().Resources[n]
@@ -229,7 +402,19 @@ resource "aidbox_user" "test" {
     given_name  = "%s"
     family_name = "%s"
   }
-  password    = "testpassword123"
+  password_wo      = "testpassword123"
+  password_version = 1
+  email       = "%s@example.com"
+  active      = true
+
+  identifier {
+    system = "https://example.com/staff"
+    value  = "%s"
+  }
+
+  data = jsonencode({
+    department = "cardiology"
+  })
 }
 `,
 		os.Getenv("AIDBOX_URL"),
@@ -238,6 +423,8 @@ resource "aidbox_user" "test" {
 		resourceID,
 		givenName,
 		familyName,
+		resourceID,
+		resourceID,
 	)
 }
 
@@ -255,7 +442,8 @@ resource "aidbox_user" "test_user" {
     given_name  = "Test"
     family_name = "User"
   }
-  password = "testpassword123"
+  password_wo      = "testpassword123"
+  password_version = 1
 }
 
 resource "aidbox_role" "test" {
@@ -264,9 +452,9 @@ resource "aidbox_role" "test" {
   user {
     id = aidbox_user.test_user.id
   }
-  extensions = {
-    "description" = "Test role created by Terraform"
-  }
+  extra_json = jsonencode({
+    description = "Test role created by Terraform"
+  })
 }
 `,
 		os.Getenv("AIDBOX_URL"),
@@ -289,10 +477,11 @@ provider "aidbox" {
 resource "aidbox_access_policy" "test" {
   resource_id = "%s"
   engine      = "%s"
-  matcho = {
+  description = "Read patients"
+  matcho = jsonencode({
     "request-method" = "get"
-    "uri"           = "/Patient"
-  }
+    "uri"            = "/Patient"
+  })
 }
 `,
 		os.Getenv("AIDBOX_URL"),
@@ -302,3 +491,62 @@ resource "aidbox_access_policy" "test" {
 		engine,
 	)
 }
+
+func testAccAidboxResourceConfig(resourceID string, name string) string {
+	return fmt.Sprintf(`
+provider "aidbox" {
+  url           = "%s"
+  client_id     = "%s"
+  client_secret = "%s"
+}
+
+resource "aidbox_resource" "test" {
+  resource_type = "Organization"
+  resource_id   = "%s"
+  resource = jsonencode({
+    name   = "%s"
+    active = true
+  })
+}
+`,
+		os.Getenv("AIDBOX_URL"),
+		os.Getenv("AIDBOX_CLIENT_ID"),
+		os.Getenv("AIDBOX_CLIENT_SECRET"),
+		resourceID,
+		name,
+	)
+}
+
+func testAccAidboxClientConfig(resourceID string, firstParty bool) string {
+	return fmt.Sprintf(`
+provider "aidbox" {
+  url           = "%s"
+  client_id     = "%s"
+  client_secret = "%s"
+}
+
+resource "aidbox_client" "test" {
+  resource_id     = "%s"
+  generate_secret = true
+  grant_types     = ["authorization_code", "client_credentials"]
+  first_party     = %t
+
+  authorization_code {
+    redirect_uri  = "https://app.example.com/callback"
+    pkce          = true
+    refresh_token = true
+  }
+
+  client_credentials {
+    access_token_expiration = 3600
+    token_format            = "jwt"
+  }
+}
+`,
+		os.Getenv("AIDBOX_URL"),
+		os.Getenv("AIDBOX_CLIENT_ID"),
+		os.Getenv("AIDBOX_CLIENT_SECRET"),
+		resourceID,
+		firstParty,
+	)
+}
@@ -2,20 +2,39 @@ package client
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"sync"
 	"time"
 )
 
+// tokenRefreshSkew is how long before expiry the access token is refreshed
+const tokenRefreshSkew = 30 * time.Second
+
 // Client represents an Aidbox API client
 type Client struct {
 	URL          string
 	ClientID     string
 	ClientSecret string
 	HTTPClient   *http.Client
-	accessToken  string
+	Retry        RetryConfig
+	// LastWriteWins disables the If-Match version check on updates
+	LastWriteWins bool
+	// IDStrategy decides how IDs are chosen for resources created without one
+	IDStrategy IDStrategy
+	// IDPrefix starts the generated IDs of the prefix strategy
+	IDPrefix string
+
+	// sleep waits between retries; tests replace it to avoid real delays
+	sleep func(ctx context.Context, d time.Duration) error
+
+	// mu guards the access token, which is shared by Terraform's parallel walkers
+	mu          sync.Mutex
+	accessToken string
+	tokenExpiry time.Time
 }
 
 // Config represents the Aidbox client configuration
@@ -23,47 +42,135 @@ type Config struct {
 	URL          string
 	ClientID     string
 	ClientSecret string
+	// Retry overrides the default retry policy; zero values, including
+	// DisableJitter, keep the defaults
+	Retry RetryConfig
+	// RequestTimeout bounds a single HTTP request; zero keeps the default of
+	// 30 seconds, so requests always have a timeout
+	RequestTimeout time.Duration
+	// LastWriteWins disables the If-Match version check on updates
+	LastWriteWins bool
+	// IDStrategy decides how IDs are chosen for resources created without
+	// one; empty keeps the default of IDStrategyPrefix
+	IDStrategy IDStrategy
+	// IDPrefix starts the generated IDs of the prefix strategy
+	IDPrefix string
 }
 
-// NewClient creates a new Aidbox API client
+// IDStrategy is how the ID of a resource created without one is chosen
+type IDStrategy string
+
+const (
+	// IDStrategyPrefix generates IDs from IDPrefix and a random suffix
+	IDStrategyPrefix IDStrategy = "prefix"
+	// IDStrategyUUID generates random UUIDs
+	IDStrategyUUID IDStrategy = "uuid"
+	// IDStrategyServer lets Aidbox assign the ID by creating with POST
+	IDStrategyServer IDStrategy = "server"
+)
+
+// defaultRequestTimeout bounds a single HTTP request unless configured otherwise
+const defaultRequestTimeout = 30 * time.Second
+
+// NewClient creates a new Aidbox API client. No request is made until the
+// client is first used; the access token is acquired lazily.
 func NewClient(config *Config) *Client {
-	client := &Client{
+	retry := DefaultRetryConfig()
+	if config.Retry.MaxAttempts > 0 {
+		retry.MaxAttempts = config.Retry.MaxAttempts
+	}
+	if config.Retry.MinBackoff > 0 {
+		retry.MinBackoff = config.Retry.MinBackoff
+	}
+	if config.Retry.MaxBackoff > 0 {
+		retry.MaxBackoff = config.Retry.MaxBackoff
+	}
+	retry.DisableJitter = config.Retry.DisableJitter
+
+	timeout := defaultRequestTimeout
+	if config.RequestTimeout > 0 {
+		timeout = config.RequestTimeout
+	}
+
+	idStrategy := IDStrategyPrefix
+	if config.IDStrategy != "" {
+		idStrategy = config.IDStrategy
+	}
+
+	return &Client{
 		URL:          config.URL,
 		ClientID:     config.ClientID,
 		ClientSecret: config.ClientSecret,
 		HTTPClient: &http.Client{
-			Timeout: time.Second * 30,
+			Timeout: timeout,
 		},
+		Retry:         retry,
+		LastWriteWins: config.LastWriteWins,
+		IDStrategy:    idStrategy,
+		IDPrefix:      config.IDPrefix,
+		sleep:         sleepContext,
 	}
-	if err := client.acquireToken(); err != nil {
-		panic(fmt.Sprintf("failed to acquire Aidbox token: %v", err))
+}
+
+// ValidateCredentials acquires an access token to check that Aidbox is
+// reachable and accepts the configured client credentials
+func (c *Client) ValidateCredentials(ctx context.Context) error {
+	_, err := c.token(ctx)
+	return err
+}
+
+// token returns a valid access token, acquiring a new one when the current
+// token is missing or about to expire
+func (c *Client) token(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.accessToken != "" && (c.tokenExpiry.IsZero() || time.Now().Add(tokenRefreshSkew).Before(c.tokenExpiry)) {
+		return c.accessToken, nil
+	}
+	if err := c.acquireToken(ctx); err != nil {
+		return "", err
 	}
-	return client
+	return c.accessToken, nil
 }
 
-// acquireToken fetches an OAuth2 token using client credentials grant
-func (c *Client) acquireToken() error {
-	form := []byte("grant_type=client_credentials")
-	req, err := http.NewRequest("POST", c.URL+"/auth/token", bytes.NewBuffer(form))
-	if err != nil {
-		return err
+// invalidateToken drops the given token so the next call re-authenticates.
+// A token that has already been replaced by another caller is left alone.
+func (c *Client) invalidateToken(token string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.accessToken == token {
+		c.accessToken = ""
+		c.tokenExpiry = time.Time{}
 	}
-	req.SetBasicAuth(c.ClientID, c.ClientSecret)
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+}
 
-	resp, err := c.HTTPClient.Do(req)
+// acquireToken fetches an OAuth2 token using client credentials grant.
+// The caller must hold c.mu.
+func (c *Client) acquireToken(ctx context.Context) error {
+	form := []byte("grant_type=client_credentials")
+	resp, err := c.execute(ctx, true, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, "POST", c.URL+"/auth/token", bytes.NewBuffer(form))
+		if err != nil {
+			return nil, err
+		}
+		req.SetBasicAuth(c.ClientID, c.ClientSecret)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return req, nil
+	})
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("failed to get token: status %d, body: %s", resp.StatusCode, string(body))
+		return newAPIError("acquiring token", resp)
 	}
 
 	var result struct {
 		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
 	}
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
 		return err
@@ -72,59 +179,124 @@ func (c *Client) acquireToken() error {
 		return fmt.Errorf("no access_token in response")
 	}
 	c.accessToken = result.AccessToken
+
+	// Tokens without expires_in are kept until Aidbox rejects them
+	c.tokenExpiry = time.Time{}
+	if result.ExpiresIn > 0 {
+		c.tokenExpiry = time.Now().Add(time.Duration(result.ExpiresIn) * time.Second)
+	}
 	return nil
 }
 
-// CreateResource creates a new resource in Aidbox
-func (c *Client) CreateResource(resourceType, id string, resourceJSON string) error {
-	url := fmt.Sprintf("%s/%s/%s", c.URL, resourceType, id)
+// do sends an authenticated request to Aidbox. When Aidbox answers 401 the
+// token is refreshed and the request is replayed once. idempotent tells
+// whether the request is safe to retry after a connection error or gateway
+// status; see execute.
+func (c *Client) do(ctx context.Context, method, url string, body []byte, header http.Header, idempotent bool) (*http.Response, error) {
+	token, err := c.token(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error acquiring token: %w", err)
+	}
+
+	resp, err := c.send(ctx, method, url, body, header, token, idempotent)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusUnauthorized {
+		return resp, nil
+	}
+	resp.Body.Close()
 
-	req, err := http.NewRequest("PUT", url, bytes.NewBufferString(resourceJSON))
+	// Re-authenticate and replay the request once
+	c.invalidateToken(token)
+	token, err = c.token(ctx)
 	if err != nil {
-		return fmt.Errorf("error creating request: %w", err)
+		return nil, fmt.Errorf("error acquiring token: %w", err)
 	}
+	return c.send(ctx, method, url, body, header, token, idempotent)
+}
+
+// send performs an HTTP request with the given bearer token, retrying transient failures
+func (c *Client) send(ctx context.Context, method, url string, body []byte, header http.Header, token string, idempotent bool) (*http.Response, error) {
+	return c.execute(ctx, idempotent, func() (*http.Request, error) {
+		var reader io.Reader
+		if body != nil {
+			reader = bytes.NewReader(body)
+		}
+
+		req, err := http.NewRequestWithContext(ctx, method, url, reader)
+		if err != nil {
+			return nil, fmt.Errorf("error creating request: %w", err)
+		}
+
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		return req, nil
+	})
+}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+c.accessToken)
+// CreateResource creates a new resource in Aidbox
+func (c *Client) CreateResource(ctx context.Context, resourceType, id string, resourceJSON string) error {
+	url := fmt.Sprintf("%s/%s/%s", c.URL, resourceType, id)
 
-	resp, err := c.HTTPClient.Do(req)
+	resp, err := c.do(ctx, "PUT", url, []byte(resourceJSON), nil, true)
 	if err != nil {
-		return fmt.Errorf("error making request: %w", err)
+		return err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("error creating resource: status %d, body: %s", resp.StatusCode, string(body))
+		return newAPIError("creating resource", resp)
 	}
 
 	return nil
 }
 
-// GetResource retrieves a resource from Aidbox
-func (c *Client) GetResource(resourceType, id string) (string, error) {
-	url := fmt.Sprintf("%s/%s/%s", c.URL, resourceType, id)
+// CreateResourceWithServerID creates a resource with POST, letting Aidbox
+// assign its ID, and returns that ID
+func (c *Client) CreateResourceWithServerID(ctx context.Context, resourceType, resourceJSON string) (string, error) {
+	url := fmt.Sprintf("%s/%s", c.URL, resourceType)
 
-	req, err := http.NewRequest("GET", url, nil)
+	resp, err := c.do(ctx, "POST", url, []byte(resourceJSON), nil, false)
 	if err != nil {
-		return "", fmt.Errorf("error creating request: %w", err)
+		return "", err
 	}
+	defer resp.Body.Close()
 
-	req.Header.Set("Authorization", "Bearer "+c.accessToken)
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return "", newAPIError("creating resource", resp)
+	}
 
-	resp, err := c.HTTPClient.Do(req)
-	if err != nil {
-		return "", fmt.Errorf("error making request: %w", err)
+	var created struct {
+		ID string `json:"id"`
 	}
-	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		return "", fmt.Errorf("error parsing created resource: %w", err)
+	}
+	if created.ID == "" {
+		return "", fmt.Errorf("error creating resource: Aidbox returned no id for the new %s", resourceType)
+	}
+	return created.ID, nil
+}
+
+// GetResource retrieves a resource from Aidbox. A missing resource is
+// reported as a *NotFoundError.
+func (c *Client) GetResource(ctx context.Context, resourceType, id string) (string, error) {
+	url := fmt.Sprintf("%s/%s/%s", c.URL, resourceType, id)
 
-	if resp.StatusCode == http.StatusNotFound {
-		return "", nil
+	resp, err := c.do(ctx, "GET", url, nil, nil, true)
+	if err != nil {
+		return "", err
 	}
+	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("error getting resource: status %d, body: %s", resp.StatusCode, string(body))
+		return "", newAPIError("getting resource", resp)
 	}
 
 	body, err := io.ReadAll(resp.Body)
@@ -135,31 +307,42 @@ func (c *Client) GetResource(resourceType, id string) (string, error) {
 	return string(body), nil
 }
 
-// UpdateResource updates an existing resource in Aidbox
-func (c *Client) UpdateResource(resourceType, id string, resourceJSON string) error {
-	return c.CreateResource(resourceType, id, resourceJSON)
-}
-
-// DeleteResource deletes a resource from Aidbox
-func (c *Client) DeleteResource(resourceType, id string) error {
+// UpdateResource updates an existing resource in Aidbox. When versionID is
+// set and LastWriteWins is off, the update only succeeds if the resource is
+// still at that version; otherwise a *PreconditionFailedError is returned.
+func (c *Client) UpdateResource(ctx context.Context, resourceType, id string, resourceJSON string, versionID string) error {
 	url := fmt.Sprintf("%s/%s/%s", c.URL, resourceType, id)
 
-	req, err := http.NewRequest("DELETE", url, nil)
+	var header http.Header
+	if versionID != "" && !c.LastWriteWins {
+		header = http.Header{"If-Match": []string{fmt.Sprintf(`W/"%s"`, versionID)}}
+	}
+
+	resp, err := c.do(ctx, "PUT", url, []byte(resourceJSON), header, true)
 	if err != nil {
-		return fmt.Errorf("error creating request: %w", err)
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return newAPIError("updating resource", resp)
 	}
 
-	req.Header.Set("Authorization", "Bearer "+c.accessToken)
+	return nil
+}
 
-	resp, err := c.HTTPClient.Do(req)
+// DeleteResource deletes a resource from Aidbox
+func (c *Client) DeleteResource(ctx context.Context, resourceType, id string) error {
+	url := fmt.Sprintf("%s/%s/%s", c.URL, resourceType, id)
+
+	resp, err := c.do(ctx, "DELETE", url, nil, nil, true)
 	if err != nil {
-		return fmt.Errorf("error making request: %w", err)
+		return err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("error deleting resource: status %d, body: %s", resp.StatusCode, string(body))
+		return newAPIError("deleting resource", resp)
 	}
 
 	return nil
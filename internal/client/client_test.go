@@ -0,0 +1,221 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestServer starts an Aidbox stand-in that issues numbered tokens with
+// the given lifetime and serves resources through handler
+func newTestServer(t *testing.T, expiresIn int, handler http.HandlerFunc) (*httptest.Server, *int32) {
+	var tokens int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/auth/token", func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&tokens, 1)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token":"token-%d","expires_in":%d}`, n, expiresIn)
+	})
+	mux.HandleFunc("/", handler)
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server, &tokens
+}
+
+func TestClientReauthenticatesOnUnauthorized(t *testing.T) {
+	server, tokens := newTestServer(t, 3600, func(w http.ResponseWriter, r *http.Request) {
+		// Only the second token is accepted
+		if r.Header.Get("Authorization") != "Bearer token-2" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, `{"resourceType":"User","id":"u1"}`)
+	})
+
+	c := NewClient(&Config{URL: server.URL, ClientID: "id", ClientSecret: "secret"})
+	ctx := context.Background()
+	body, err := c.GetResource(ctx, "User", "u1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if body == "" {
+		t.Fatal("expected resource body")
+	}
+	if got := atomic.LoadInt32(tokens); got != 2 {
+		t.Errorf("expected 2 token requests, got %d", got)
+	}
+}
+
+func TestClientRefreshesExpiringToken(t *testing.T) {
+	// Tokens expire within the refresh skew, so every call re-authenticates
+	server, tokens := newTestServer(t, 1, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{}`)
+	})
+
+	c := NewClient(&Config{URL: server.URL, ClientID: "id", ClientSecret: "secret"})
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		if _, err := c.GetResource(ctx, "User", "u1"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if got := atomic.LoadInt32(tokens); got != 2 {
+		t.Errorf("expected 2 token requests, got %d", got)
+	}
+}
+
+func TestClientRequestTimeout(t *testing.T) {
+	c := NewClient(&Config{URL: "http://localhost", ClientID: "id", ClientSecret: "secret"})
+	if c.HTTPClient.Timeout != 30*time.Second {
+		t.Errorf("expected default timeout of 30s, got %s", c.HTTPClient.Timeout)
+	}
+
+	c = NewClient(&Config{URL: "http://localhost", ClientID: "id", ClientSecret: "secret", RequestTimeout: 2 * time.Minute})
+	if c.HTTPClient.Timeout != 2*time.Minute {
+		t.Errorf("expected configured timeout of 2m, got %s", c.HTTPClient.Timeout)
+	}
+}
+
+func TestClientAuthenticatesLazily(t *testing.T) {
+	server, tokens := newTestServer(t, 3600, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{}`)
+	})
+
+	c := NewClient(&Config{URL: server.URL, ClientID: "id", ClientSecret: "secret"})
+	ctx := context.Background()
+	if got := atomic.LoadInt32(tokens); got != 0 {
+		t.Fatalf("expected no token requests before first use, got %d", got)
+	}
+	if err := c.ValidateCredentials(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := atomic.LoadInt32(tokens); got != 1 {
+		t.Errorf("expected 1 token request, got %d", got)
+	}
+}
+
+func TestClientValidateCredentialsFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	t.Cleanup(server.Close)
+
+	c := NewClient(&Config{URL: server.URL, ClientID: "id", ClientSecret: "wrong"})
+	ctx := context.Background()
+	if err := c.ValidateCredentials(ctx); err == nil {
+		t.Fatal("expected an error for rejected credentials")
+	}
+}
+
+func TestClientUpdateSendsIfMatch(t *testing.T) {
+	var ifMatch string
+	server, _ := newTestServer(t, 3600, func(w http.ResponseWriter, r *http.Request) {
+		ifMatch = r.Header.Get("If-Match")
+		if ifMatch == `W/"1"` {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+		fmt.Fprint(w, `{}`)
+	})
+
+	c := NewClient(&Config{URL: server.URL, ClientID: "id", ClientSecret: "secret"})
+	ctx := context.Background()
+
+	if err := c.UpdateResource(ctx, "User", "u1", `{}`, "2"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ifMatch != `W/"2"` {
+		t.Errorf("expected If-Match W/\"2\", got %q", ifMatch)
+	}
+
+	err := c.UpdateResource(ctx, "User", "u1", `{}`, "1")
+	var preconditionFailed *PreconditionFailedError
+	if !errors.As(err, &preconditionFailed) {
+		t.Fatalf("expected PreconditionFailedError, got %T: %v", err, err)
+	}
+
+	// Last write wins skips the version check entirely
+	c.LastWriteWins = true
+	if err := c.UpdateResource(ctx, "User", "u1", `{}`, "1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ifMatch != "" {
+		t.Errorf("expected no If-Match header, got %q", ifMatch)
+	}
+}
+
+func TestClientCreateResourceWithServerID(t *testing.T) {
+	var method, path string
+	server, _ := newTestServer(t, 3600, func(w http.ResponseWriter, r *http.Request) {
+		method, path = r.Method, r.URL.Path
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, `{"resourceType":"Organization","id":"0f8b2c1e"}`)
+	})
+
+	c := NewClient(&Config{URL: server.URL, ClientID: "id", ClientSecret: "secret"})
+	id, err := c.CreateResourceWithServerID(context.Background(), "Organization", `{"resourceType":"Organization"}`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if method != http.MethodPost || path != "/Organization" {
+		t.Errorf("expected POST /Organization, got %s %s", method, path)
+	}
+	if id != "0f8b2c1e" {
+		t.Errorf("expected id 0f8b2c1e, got %q", id)
+	}
+}
+
+func TestClientTestPolicy(t *testing.T) {
+	var method, path string
+	var payload map[string]interface{}
+	server, _ := newTestServer(t, 3600, func(w http.ResponseWriter, r *http.Request) {
+		method, path = r.Method, r.URL.Path
+		json.NewDecoder(r.Body).Decode(&payload)
+		fmt.Fprint(w, `{
+			"operation": {"id": "FhirSearch"},
+			"policies": {
+				"read-patients": {"eval-result": true},
+				"admin": {"eval-result": false}
+			}
+		}`)
+	})
+
+	c := NewClient(&Config{URL: server.URL, ClientID: "id", ClientSecret: "secret"})
+	result, err := c.TestPolicy(context.Background(), PolicyTestRequest{
+		Method: "GET",
+		URI:    "/Patient",
+		UserID: "jane",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if method != http.MethodPost || path != "/auth/test-policy" {
+		t.Errorf("expected POST /auth/test-policy, got %s %s", method, path)
+	}
+	request, _ := payload["request"].(map[string]interface{})
+	user, _ := payload["user"].(map[string]interface{})
+	if request["request-method"] != "get" || request["uri"] != "/Patient" || user["id"] != "jane" {
+		t.Errorf("unexpected payload %v", payload)
+	}
+
+	if result.Operation != "FhirSearch" || len(result.Policies) != 2 || result.Policies[0].PolicyID != "admin" {
+		t.Errorf("unexpected result %+v", result)
+	}
+	if !result.Allowed(nil) || !result.Allowed([]string{"read-patients"}) {
+		t.Error("expected the request to be allowed by read-patients")
+	}
+	if result.Allowed([]string{"admin"}) {
+		t.Error("expected the request to be denied by admin alone")
+	}
+	if missing := result.Missing([]string{"admin", "adimn"}); len(missing) != 1 || missing[0] != "adimn" {
+		t.Errorf("expected adimn to be missing, got %v", missing)
+	}
+}
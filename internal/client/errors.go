@@ -0,0 +1,155 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Issue is a single entry of a FHIR OperationOutcome returned by Aidbox
+type Issue struct {
+	Severity    string   `json:"severity"`
+	Code        string   `json:"code"`
+	Diagnostics string   `json:"diagnostics"`
+	Expression  []string `json:"expression"`
+	// Location is the deprecated predecessor of Expression
+	Location []string `json:"location"`
+}
+
+// Path returns the element the issue refers to, if any
+func (i Issue) Path() string {
+	if len(i.Expression) > 0 {
+		return strings.Join(i.Expression, ", ")
+	}
+	return strings.Join(i.Location, ", ")
+}
+
+// String renders the issue as "severity code: diagnostics (at path)"
+func (i Issue) String() string {
+	var b strings.Builder
+	b.WriteString(i.Severity)
+	if i.Code != "" {
+		if b.Len() > 0 {
+			b.WriteString(" ")
+		}
+		b.WriteString(i.Code)
+	}
+	if i.Diagnostics != "" {
+		if b.Len() > 0 {
+			b.WriteString(": ")
+		}
+		b.WriteString(i.Diagnostics)
+	}
+	if path := i.Path(); path != "" {
+		fmt.Fprintf(&b, " (at %s)", path)
+	}
+	return b.String()
+}
+
+// APIError is returned when Aidbox answers with an unsuccessful status.
+// More specific errors such as NotFoundError wrap it, so both can be
+// matched with errors.As.
+type APIError struct {
+	// Operation describes what the client was doing, e.g. "creating resource"
+	Operation  string
+	StatusCode int
+	// Issues holds the parsed OperationOutcome issues, if the body was one
+	Issues []Issue
+	// Body is the raw response body
+	Body string
+}
+
+func (e *APIError) Error() string {
+	if len(e.Issues) == 0 {
+		return fmt.Sprintf("error %s: status %d, body: %s", e.Operation, e.StatusCode, e.Body)
+	}
+
+	issues := make([]string, len(e.Issues))
+	for i, issue := range e.Issues {
+		issues[i] = issue.String()
+	}
+	return fmt.Sprintf("error %s: status %d: %s", e.Operation, e.StatusCode, strings.Join(issues, "; "))
+}
+
+// NotFoundError is returned for 404 and 410 responses
+type NotFoundError struct{ *APIError }
+
+func (e *NotFoundError) Unwrap() error { return e.APIError }
+
+// ConflictError is returned for 409 responses
+type ConflictError struct{ *APIError }
+
+func (e *ConflictError) Unwrap() error { return e.APIError }
+
+// PreconditionFailedError is returned for 412 responses, i.e. when a
+// versioned update finds the resource was modified in the meantime
+type PreconditionFailedError struct{ *APIError }
+
+func (e *PreconditionFailedError) Unwrap() error { return e.APIError }
+
+// ValidationError is returned for 400 and 422 responses
+type ValidationError struct{ *APIError }
+
+func (e *ValidationError) Unwrap() error { return e.APIError }
+
+// UnauthorizedError is returned for 401 responses
+type UnauthorizedError struct{ *APIError }
+
+func (e *UnauthorizedError) Unwrap() error { return e.APIError }
+
+// ForbiddenError is returned for 403 responses
+type ForbiddenError struct{ *APIError }
+
+func (e *ForbiddenError) Unwrap() error { return e.APIError }
+
+// ServerError is returned for 5xx responses
+type ServerError struct{ *APIError }
+
+func (e *ServerError) Unwrap() error { return e.APIError }
+
+// newAPIError reads the response body and builds the error matching its status
+func newAPIError(operation string, resp *http.Response) error {
+	body, _ := io.ReadAll(resp.Body)
+	apiErr := &APIError{
+		Operation:  operation,
+		StatusCode: resp.StatusCode,
+		Issues:     parseOperationOutcome(body),
+		Body:       string(body),
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return &NotFoundError{apiErr}
+	case resp.StatusCode == http.StatusConflict:
+		return &ConflictError{apiErr}
+	case resp.StatusCode == http.StatusPreconditionFailed:
+		return &PreconditionFailedError{apiErr}
+	case resp.StatusCode == http.StatusBadRequest || resp.StatusCode == http.StatusUnprocessableEntity:
+		return &ValidationError{apiErr}
+	case resp.StatusCode == http.StatusUnauthorized:
+		return &UnauthorizedError{apiErr}
+	case resp.StatusCode == http.StatusForbidden:
+		return &ForbiddenError{apiErr}
+	case resp.StatusCode >= 500:
+		return &ServerError{apiErr}
+	}
+	return apiErr
+}
+
+// parseOperationOutcome extracts the issues of an OperationOutcome body.
+// Bodies that are not an OperationOutcome yield no issues.
+func parseOperationOutcome(body []byte) []Issue {
+	var outcome struct {
+		ResourceType string  `json:"resourceType"`
+		Issue        []Issue `json:"issue"`
+	}
+	if err := json.Unmarshal(body, &outcome); err != nil {
+		return nil
+	}
+	if outcome.ResourceType != "OperationOutcome" {
+		return nil
+	}
+	return outcome.Issue
+}
@@ -0,0 +1,96 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+const testOperationOutcome = `{
+  "resourceType": "OperationOutcome",
+  "id": "invalid",
+  "text": {"status": "generated", "div": "Invalid resource"},
+  "issue": [
+    {
+      "severity": "fatal",
+      "code": "invalid",
+      "expression": ["AccessPolicy.engine"],
+      "diagnostics": "expected one of json-schema, allow, sql, complex, matcho"
+    },
+    {
+      "severity": "error",
+      "code": "required",
+      "location": ["AccessPolicy.matcho"],
+      "diagnostics": "matcho is required"
+    }
+  ]
+}`
+
+func TestClientTypedErrors(t *testing.T) {
+	server, _ := newTestServer(t, 3600, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/User/missing":
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"resourceType":"OperationOutcome","issue":[{"severity":"fatal","code":"not-found","diagnostics":"Resource User/missing not found"}]}`)
+		case "/AccessPolicy/invalid":
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			fmt.Fprint(w, testOperationOutcome)
+		case "/User/conflict":
+			w.WriteHeader(http.StatusConflict)
+			fmt.Fprint(w, `plain text`)
+		case "/User/forbidden":
+			w.WriteHeader(http.StatusForbidden)
+		}
+	})
+
+	c := NewClient(&Config{URL: server.URL, ClientID: "id", ClientSecret: "secret"})
+	ctx := context.Background()
+
+	_, err := c.GetResource(ctx, "User", "missing")
+	var notFound *NotFoundError
+	if !errors.As(err, &notFound) {
+		t.Fatalf("expected NotFoundError, got %T: %v", err, err)
+	}
+
+	err = c.CreateResource(ctx, "AccessPolicy", "invalid", `{}`)
+	var validation *ValidationError
+	if !errors.As(err, &validation) {
+		t.Fatalf("expected ValidationError, got %T: %v", err, err)
+	}
+	if len(validation.Issues) != 2 {
+		t.Fatalf("expected 2 issues, got %d", len(validation.Issues))
+	}
+	if got := validation.Issues[0].Path(); got != "AccessPolicy.engine" {
+		t.Errorf("expected expression path, got %q", got)
+	}
+	if got := validation.Issues[1].Path(); got != "AccessPolicy.matcho" {
+		t.Errorf("expected location path fallback, got %q", got)
+	}
+	if !strings.Contains(err.Error(), "error required: matcho is required (at AccessPolicy.matcho)") {
+		t.Errorf("unexpected error message: %s", err)
+	}
+
+	// Every typed error is also an APIError
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("expected APIError with status 422, got %v", apiErr)
+	}
+
+	err = c.DeleteResource(ctx, "User", "conflict")
+	var conflict *ConflictError
+	if !errors.As(err, &conflict) {
+		t.Fatalf("expected ConflictError, got %T: %v", err, err)
+	}
+	if conflict.Issues != nil || conflict.Body != "plain text" {
+		t.Errorf("expected raw body without issues, got %+v", conflict.APIError)
+	}
+
+	_, err = c.GetResource(ctx, "User", "forbidden")
+	var forbidden *ForbiddenError
+	if !errors.As(err, &forbidden) {
+		t.Fatalf("expected ForbiddenError, got %T: %v", err, err)
+	}
+}
@@ -0,0 +1,121 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// PolicyTestRequest is a sample request for Aidbox's access policy debugger
+type PolicyTestRequest struct {
+	Method  string
+	URI     string
+	Headers map[string]string
+	// Body is the decoded JSON body of the request, if any
+	Body interface{}
+	// UserID and ClientID make the request on behalf of a User or Client
+	UserID   string
+	ClientID string
+}
+
+// PolicyEvaluation is the result of a single access policy
+type PolicyEvaluation struct {
+	PolicyID string
+	Allowed  bool
+}
+
+// PolicyTestResult is how Aidbox evaluated a sample request
+type PolicyTestResult struct {
+	// Operation is the Aidbox operation the request was routed to
+	Operation string
+	// Policies are ordered by policy ID
+	Policies []PolicyEvaluation
+}
+
+// Allowed reports whether any of the policies allows the request, as Aidbox
+// grants a request when one policy does. Only the listed policies are
+// considered unless policyIDs is empty.
+func (r *PolicyTestResult) Allowed(policyIDs []string) bool {
+	for _, p := range r.Policies {
+		if p.Allowed && (len(policyIDs) == 0 || slices.Contains(policyIDs, p.PolicyID)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Missing returns the policyIDs that Aidbox did not evaluate, usually because
+// no such policy exists
+func (r *PolicyTestResult) Missing(policyIDs []string) []string {
+	var missing []string
+	for _, id := range policyIDs {
+		if !slices.ContainsFunc(r.Policies, func(p PolicyEvaluation) bool { return p.PolicyID == id }) {
+			missing = append(missing, id)
+		}
+	}
+	return missing
+}
+
+// TestPolicy evaluates a sample request against the access policies stored
+// in Aidbox with the /auth/test-policy endpoint. The request itself is not
+// executed.
+func (c *Client) TestPolicy(ctx context.Context, req PolicyTestRequest) (*PolicyTestResult, error) {
+	request := map[string]interface{}{
+		"request-method": strings.ToLower(req.Method),
+		"uri":            req.URI,
+	}
+	if len(req.Headers) > 0 {
+		request["headers"] = req.Headers
+	}
+	if req.Body != nil {
+		request["body"] = req.Body
+	}
+	payload := map[string]interface{}{"request": request}
+	if req.UserID != "" {
+		payload["user"] = map[string]interface{}{"id": req.UserID}
+	}
+	if req.ClientID != "" {
+		payload["client"] = map[string]interface{}{"id": req.ClientID}
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding policy test request: %w", err)
+	}
+
+	// The request is only evaluated, so replaying it is safe
+	resp, err := c.do(ctx, "POST", c.URL+"/auth/test-policy", body, nil, true)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newAPIError("testing access policies", resp)
+	}
+
+	var evaluated struct {
+		Operation struct {
+			ID string `json:"id"`
+		} `json:"operation"`
+		Policies map[string]struct {
+			EvalResult bool `json:"eval-result"`
+		} `json:"policies"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&evaluated); err != nil {
+		return nil, fmt.Errorf("error parsing policy test result: %w", err)
+	}
+
+	result := &PolicyTestResult{Operation: evaluated.Operation.ID}
+	for id, p := range evaluated.Policies {
+		result.Policies = append(result.Policies, PolicyEvaluation{PolicyID: id, Allowed: p.EvalResult})
+	}
+	sort.Slice(result.Policies, func(i, j int) bool {
+		return result.Policies[i].PolicyID < result.Policies[j].PolicyID
+	})
+	return result, nil
+}
@@ -0,0 +1,139 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryConfig controls how requests failing with transient errors are retried
+type RetryConfig struct {
+	// MaxAttempts is the total number of attempts, including the first one
+	MaxAttempts int
+	// MinBackoff is the wait before the first retry; it doubles on every retry
+	MinBackoff time.Duration
+	// MaxBackoff caps both the exponential backoff and Retry-After
+	MaxBackoff time.Duration
+	// DisableJitter waits the exact backoff instead of a random wait
+	// between half and the full backoff, so the zero value keeps jitter on
+	DisableJitter bool
+}
+
+// DefaultRetryConfig returns the retry policy used when none is configured
+func DefaultRetryConfig() RetryConfig {
+	return RetryConfig{
+		MaxAttempts: 4,
+		MinBackoff:  time.Second,
+		MaxBackoff:  30 * time.Second,
+	}
+}
+
+// retryableStatusCodes are the statuses Aidbox returns while overloaded or restarting
+var retryableStatusCodes = map[int]bool{
+	http.StatusTooManyRequests:    true,
+	http.StatusBadGateway:         true,
+	http.StatusServiceUnavailable: true,
+	http.StatusGatewayTimeout:     true,
+}
+
+// execute sends the request built by newRequest, retrying connection errors
+// and transient statuses according to the client's retry policy. The request
+// is rebuilt for every attempt so that its body can be replayed. Retries stop
+// when ctx is cancelled or its deadline would pass before the next attempt.
+//
+// Requests that are not idempotent, such as a POST creating a resource, may
+// already have been applied when a connection error or gateway status is
+// seen, so they are only retried on 429 Too Many Requests.
+func (c *Client) execute(ctx context.Context, idempotent bool, newRequest func() (*http.Request, error)) (*http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		req, err := newRequest()
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := c.HTTPClient.Do(req)
+		if err != nil {
+			err = fmt.Errorf("error making request: %w", err)
+		}
+		if attempt >= c.Retry.MaxAttempts || ctx.Err() != nil || (err == nil && !retryableStatusCodes[resp.StatusCode]) {
+			return resp, err
+		}
+		if !idempotent && (err != nil || resp.StatusCode != http.StatusTooManyRequests) {
+			return resp, err
+		}
+
+		wait := c.Retry.backoff(attempt, resp)
+		if deadline, ok := ctx.Deadline(); ok && time.Now().Add(wait).After(deadline) {
+			return resp, err
+		}
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		if sleepErr := c.sleep(ctx, wait); sleepErr != nil {
+			return nil, sleepErr
+		}
+	}
+}
+
+// sleepContext waits for d or until ctx is done, whichever comes first
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// backoff returns how long to wait before the next attempt. A Retry-After
+// header takes precedence over the exponential backoff.
+func (r RetryConfig) backoff(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if wait, ok := retryAfter(resp.Header.Get("Retry-After")); ok {
+			if wait > r.MaxBackoff {
+				wait = r.MaxBackoff
+			}
+			return wait
+		}
+	}
+
+	wait := r.MinBackoff
+	for i := 1; i < attempt && wait < r.MaxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > r.MaxBackoff {
+		wait = r.MaxBackoff
+	}
+
+	if !r.DisableJitter && wait > 0 {
+		half := wait / 2
+		wait = half + time.Duration(rand.Int63n(int64(wait-half)+1))
+	}
+	return wait
+}
+
+// retryAfter parses a Retry-After header given either in seconds or as an HTTP date
+func retryAfter(header string) (time.Duration, bool) {
+	if header == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(header); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(header); err == nil {
+		wait := time.Until(date)
+		if wait < 0 {
+			wait = 0
+		}
+		return wait, true
+	}
+	return 0, false
+}
@@ -0,0 +1,158 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClientRetriesTransientErrors(t *testing.T) {
+	var calls int32
+	server, _ := newTestServer(t, 3600, func(w http.ResponseWriter, r *http.Request) {
+		switch atomic.AddInt32(&calls, 1) {
+		case 1:
+			w.WriteHeader(http.StatusBadGateway)
+		case 2:
+			w.Header().Set("Retry-After", "7")
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			fmt.Fprint(w, `{}`)
+		}
+	})
+
+	// Exact waits keep the assertion deterministic
+	c := NewClient(&Config{URL: server.URL, ClientID: "id", ClientSecret: "secret", Retry: RetryConfig{DisableJitter: true}})
+	ctx := context.Background()
+	var waits []time.Duration
+	c.sleep = func(ctx context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		return nil
+	}
+
+	if _, err := c.GetResource(ctx, "User", "u1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := atomic.LoadInt32(&calls); got != 3 {
+		t.Fatalf("expected 3 attempts, got %d", got)
+	}
+	if len(waits) != 2 || waits[0] != time.Second || waits[1] != 7*time.Second {
+		t.Errorf("expected waits [1s 7s], got %v", waits)
+	}
+}
+
+func TestClientGivesUpAfterMaxAttempts(t *testing.T) {
+	var calls int32
+	server, _ := newTestServer(t, 3600, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusTooManyRequests)
+	})
+
+	c := NewClient(&Config{URL: server.URL, ClientID: "id", ClientSecret: "secret", Retry: RetryConfig{MaxAttempts: 3}})
+	ctx := context.Background()
+	c.sleep = func(context.Context, time.Duration) error { return nil }
+
+	if _, err := c.GetResource(ctx, "User", "u1"); err == nil {
+		t.Fatal("expected an error after exhausting retries")
+	}
+	if got := atomic.LoadInt32(&calls); got != 3 {
+		t.Errorf("expected 3 attempts, got %d", got)
+	}
+}
+
+func TestClientDoesNotRetryCreateWithServerID(t *testing.T) {
+	var calls int32
+	server, _ := newTestServer(t, 3600, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusBadGateway)
+	})
+
+	c := NewClient(&Config{URL: server.URL, ClientID: "id", ClientSecret: "secret"})
+	c.sleep = func(context.Context, time.Duration) error { return nil }
+
+	// The POST may have reached Aidbox, so replaying it could create a duplicate
+	if _, err := c.CreateResourceWithServerID(context.Background(), "Organization", `{}`); err == nil {
+		t.Fatal("expected an error")
+	}
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Errorf("expected 1 attempt, got %d", got)
+	}
+}
+
+func TestClientRetriesTestPolicy(t *testing.T) {
+	var calls int32
+	server, _ := newTestServer(t, 3600, func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		fmt.Fprint(w, `{"policies": {}}`)
+	})
+
+	c := NewClient(&Config{URL: server.URL, ClientID: "id", ClientSecret: "secret"})
+	c.sleep = func(context.Context, time.Duration) error { return nil }
+
+	// The POST only evaluates the request, so it is retried like a GET
+	if _, err := c.TestPolicy(context.Background(), PolicyTestRequest{Method: "GET", URI: "/Patient"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := atomic.LoadInt32(&calls); got != 2 {
+		t.Errorf("expected 2 attempts, got %d", got)
+	}
+}
+
+func TestClientStopsRetryingAtDeadline(t *testing.T) {
+	var calls int32
+	server, _ := newTestServer(t, 3600, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+
+	c := NewClient(&Config{URL: server.URL, ClientID: "id", ClientSecret: "secret"})
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+
+	// The first backoff of 1s would overrun the deadline, so no retry is made
+	if _, err := c.GetResource(ctx, "User", "u1"); err == nil {
+		t.Fatal("expected an error")
+	}
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Errorf("expected 1 attempt, got %d", got)
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	r := RetryConfig{MaxAttempts: 10, MinBackoff: time.Second, MaxBackoff: 5 * time.Second, DisableJitter: true}
+
+	expected := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+	for i, want := range expected {
+		if got := r.backoff(i+1, nil); got != want {
+			t.Errorf("attempt %d: expected %s, got %s", i+1, want, got)
+		}
+	}
+
+	// Retry-After is capped by MaxBackoff
+	resp := &http.Response{Header: http.Header{"Retry-After": []string{"120"}}}
+	if got := r.backoff(1, resp); got != 5*time.Second {
+		t.Errorf("expected Retry-After to be capped at 5s, got %s", got)
+	}
+
+	// Jitter keeps the wait between half and the full backoff
+	r.DisableJitter = false
+	for i := 0; i < 100; i++ {
+		if got := r.backoff(3, nil); got < 2*time.Second || got > 4*time.Second {
+			t.Fatalf("jittered backoff %s out of range", got)
+		}
+	}
+}
+
+func TestNewClientKeepsJitterByDefault(t *testing.T) {
+	if c := NewClient(&Config{URL: "http://aidbox"}); c.Retry.DisableJitter {
+		t.Error("expected a zero-value Config to keep jitter on")
+	}
+	if c := NewClient(&Config{URL: "http://aidbox", Retry: RetryConfig{DisableJitter: true}}); !c.Retry.DisableJitter {
+		t.Error("expected jitter to be disabled")
+	}
+}
@@ -1,10 +1,15 @@
 package resource
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/flawless/terraform-provider-aidbox/internal/client"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 )
 
@@ -12,15 +17,34 @@ import (
 type BaseResource struct {
 	ResourceType string
 	Schema       map[string]*schema.Schema
-	CreateFunc   func(d *schema.ResourceData, m interface{}) error
-	ReadFunc     func(d *schema.ResourceData, m interface{}) error
-	UpdateFunc   func(d *schema.ResourceData, m interface{}) error
-	DeleteFunc   func(d *schema.ResourceData, m interface{}) error
+	CreateFunc   schema.CreateContextFunc
+	ReadFunc     schema.ReadContextFunc
+	UpdateFunc   schema.UpdateContextFunc
+	DeleteFunc   schema.DeleteContextFunc
+	// ExpandFunc adds resource-specific values to the body built from Fields
+	ExpandFunc ExpandFunc
+	// Fields is the declarative mapping of the resource-specific attributes
+	// to the resource body, see SetFields
+	Fields   []Field
+	Timeouts *schema.ResourceTimeout
+	// CustomizeDiff validates the planned resource as a whole
+	CustomizeDiff schema.CustomizeDiffFunc
+	// StateUpgraders migrate state from schema version 1 onwards. The
+	// upgrade from version 0, which replaced extensions with extra_json,
+	// is always applied first.
+	StateUpgraders []schema.StateUpgrader
 }
 
+// ExpandFunc completes the resource body built from the declared fields
+// before it is sent on create or update
+type ExpandFunc func(ctx context.Context, d *schema.ResourceData, m interface{}, resourceMap map[string]interface{}) error
+
+// defaultTimeout bounds each CRUD operation unless a timeouts block overrides it
+const defaultTimeout = 20 * time.Minute
+
 // NewBaseResource creates a new base resource with common schema fields
 func NewBaseResource(resourceType string) *BaseResource {
-	return &BaseResource{
+	b := &BaseResource{
 		ResourceType: resourceType,
 		Schema: map[string]*schema.Schema{
 			"id": {
@@ -28,47 +52,38 @@ func NewBaseResource(resourceType string) *BaseResource {
 				Computed: true,
 			},
 			"resource_id": {
-				Type:     schema.TypeString,
-				Optional: true,
-				ForceNew: true,
+				Type:        schema.TypeString,
+				Optional:    true,
+				Computed:    true,
+				ForceNew:    true,
+				Description: "The ID of the resource in Aidbox. Generated according to the provider's id_strategy when omitted",
 			},
 			"resource_type": {
 				Type:     schema.TypeString,
 				Computed: true,
 			},
-			"meta": {
-				Type:     schema.TypeList,
-				Computed: true,
-				Elem: &schema.Resource{
-					Schema: map[string]*schema.Schema{
-						"version_id": {
-							Type:     schema.TypeString,
-							Computed: true,
-						},
-						"created_at": {
-							Type:     schema.TypeString,
-							Computed: true,
-						},
-						"last_updated": {
-							Type:     schema.TypeString,
-							Computed: true,
-						},
-					},
-				},
-			},
-			// Extensions field for additional properties
-			"extensions": {
-				Type:        schema.TypeMap,
+			"adopt_existing": {
+				Type:        schema.TypeBool,
 				Optional:    true,
-				Elem:        &schema.Schema{Type: schema.TypeString},
-				Description: "Additional fields that are not explicitly defined in the schema",
+				Default:     false,
+				Description: "Take over a resource that already exists in Aidbox with the same ID on create, overwriting it. By default creating such a resource fails.",
 			},
+			"meta": metaSchema(),
+			// Additional properties, keeping their JSON types
+			"extra_json": extraJSONSchema(),
 		},
-		CreateFunc: ResourceBaseCreate,
-		ReadFunc:   ResourceBaseRead,
-		UpdateFunc: ResourceBaseUpdate,
 		DeleteFunc: ResourceBaseDelete,
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(defaultTimeout),
+			Read:   schema.DefaultTimeout(defaultTimeout),
+			Update: schema.DefaultTimeout(defaultTimeout),
+			Delete: schema.DefaultTimeout(defaultTimeout),
+		},
 	}
+	b.CreateFunc = b.Create
+	b.ReadFunc = b.Read
+	b.UpdateFunc = b.Update
+	return b
 }
 
 // AddSchema adds a new schema field to the base resource
@@ -76,193 +91,273 @@ func (b *BaseResource) AddSchema(name string, schema *schema.Schema) {
 	b.Schema[name] = schema
 }
 
+// RemoveSchema removes a schema field from the base resource
+func (b *BaseResource) RemoveSchema(name string) {
+	delete(b.Schema, name)
+}
+
 // SetCreateFunc sets a custom create function
-func (b *BaseResource) SetCreateFunc(f func(d *schema.ResourceData, m interface{}) error) {
+func (b *BaseResource) SetCreateFunc(f schema.CreateContextFunc) {
 	b.CreateFunc = f
 }
 
 // SetReadFunc sets a custom read function
-func (b *BaseResource) SetReadFunc(f func(d *schema.ResourceData, m interface{}) error) {
+func (b *BaseResource) SetReadFunc(f schema.ReadContextFunc) {
 	b.ReadFunc = f
 }
 
+// SetFields declares the resource-specific attributes and their JSON paths.
+// The schema, the request body on create and update, the mapping back on
+// read and the keys excluded from extra_json are all derived from them.
+func (b *BaseResource) SetFields(fields ...Field) {
+	b.Fields = fields
+	for _, f := range fields {
+		b.Schema[f.Attribute] = f.Schema()
+	}
+}
+
+// SetExpandFunc sets the function completing the body built from the fields
+func (b *BaseResource) SetExpandFunc(f ExpandFunc) {
+	b.ExpandFunc = f
+}
+
 // SetUpdateFunc sets a custom update function
-func (b *BaseResource) SetUpdateFunc(f func(d *schema.ResourceData, m interface{}) error) {
+func (b *BaseResource) SetUpdateFunc(f schema.UpdateContextFunc) {
 	b.UpdateFunc = f
 }
 
 // SetDeleteFunc sets a custom delete function
-func (b *BaseResource) SetDeleteFunc(f func(d *schema.ResourceData, m interface{}) error) {
+func (b *BaseResource) SetDeleteFunc(f schema.DeleteContextFunc) {
 	b.DeleteFunc = f
 }
 
+// SetCustomizeDiff sets the function validating the planned resource
+func (b *BaseResource) SetCustomizeDiff(f schema.CustomizeDiffFunc) {
+	b.CustomizeDiff = f
+}
+
+// SetTimeouts overrides the default operation timeouts
+func (b *BaseResource) SetTimeouts(timeouts *schema.ResourceTimeout) {
+	b.Timeouts = timeouts
+}
+
+// AddStateUpgrader registers the upgrader for the next schema version
+func (b *BaseResource) AddStateUpgrader(upgrader schema.StateUpgrader) {
+	b.StateUpgraders = append(b.StateUpgraders, upgrader)
+}
+
 // ToResource converts the base resource to a schema.Resource
 func (b *BaseResource) ToResource() *schema.Resource {
+	// Version 1 is described by the next upgrader, if any
+	v1 := (&schema.Resource{Schema: b.Schema}).CoreConfigSchema().ImpliedType()
+	if len(b.StateUpgraders) > 0 {
+		v1 = b.StateUpgraders[0].Type
+	}
+	stateUpgraders := append([]schema.StateUpgrader{extensionsStateUpgrader(v1)}, b.StateUpgraders...)
 	return &schema.Resource{
-		Create: b.CreateFunc,
-		Read:   b.ReadFunc,
-		Update: b.UpdateFunc,
-		Delete: b.DeleteFunc,
-		Schema: b.Schema,
+		CreateContext: b.CreateFunc,
+		ReadContext:   b.ReadFunc,
+		UpdateContext: b.UpdateFunc,
+		DeleteContext: b.DeleteFunc,
+		Importer: &schema.ResourceImporter{
+			StateContext: b.Import,
+		},
+		Schema:        b.Schema,
+		CustomizeDiff: b.CustomizeDiff,
+		// Terraform cancels the context passed to each operation once its
+		// timeout elapses, which aborts in-flight requests and retries
+		Timeouts:       b.Timeouts,
+		SchemaVersion:  len(stateUpgraders),
+		StateUpgraders: stateUpgraders,
 	}
 }
 
-// ResourceBaseCreate handles the creation of a new Aidbox resource
-func ResourceBaseCreate(d *schema.ResourceData, m interface{}) error {
-	client := m.(*client.Client)
-
-	// Get the resource ID
-	resourceID := d.Get("resource_id").(string)
-	if resourceID == "" {
-		resourceID = fmt.Sprintf("tf-%s", d.Get("id").(string))
+// Read refreshes the resource from Aidbox, including the declared fields
+func (b *BaseResource) Read(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	if b.ResourceType != "" {
+		d.Set("resource_type", b.ResourceType)
 	}
+	return readResource(ctx, d, m, b.Fields)
+}
 
-	// Create a map for the resource
-	resourceMap := make(map[string]interface{})
-	resourceMap["resourceType"] = d.Get("resource_type").(string)
-	resourceMap["id"] = resourceID
+// Expand builds the resource body from the declared fields, the expand
+// function, extra_json and meta
+func (b *BaseResource) Expand(ctx context.Context, d *schema.ResourceData, m interface{}) (map[string]interface{}, error) {
+	resourceMap, err := ExpandFields(d, b.Fields)
+	if err != nil {
+		return nil, err
+	}
+	resourceMap["resourceType"] = b.ResourceType
 
-	// Add extensions if provided
-	if extensions, ok := d.GetOk("extensions"); ok {
-		extensionsMap := extensions.(map[string]interface{})
-		for k, v := range extensionsMap {
-			resourceMap[k] = v
+	if b.ExpandFunc != nil {
+		if err := b.ExpandFunc(ctx, d, m, resourceMap); err != nil {
+			return nil, err
 		}
 	}
 
-	// Convert to JSON
-	resourceJSON, err := json.Marshal(resourceMap)
+	// Add extra fields if provided
+	extra, err := ExpandExtraJSON(d)
 	if err != nil {
-		return fmt.Errorf("failed to marshal resource: %w", err)
+		return nil, err
 	}
-
-	if err := client.CreateResource(d.Get("resource_type").(string), resourceID, string(resourceJSON)); err != nil {
-		return err
+	for k, v := range extra {
+		resourceMap[k] = v
 	}
 
-	d.SetId(resourceID)
-	return ResourceBaseRead(d, m)
+	// Add configurable meta
+	if meta := ExpandMeta(d); meta != nil {
+		resourceMap["meta"] = meta
+	}
+	return resourceMap, nil
 }
 
-// ResourceBaseRead handles reading an existing Aidbox resource
-func ResourceBaseRead(d *schema.ResourceData, m interface{}) error {
-	client := m.(*client.Client)
+// Create creates the resource from its declared fields with a single request
+func (b *BaseResource) Create(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	d.Set("resource_type", b.ResourceType)
 
-	resourceID := d.Id()
-	resourceType := d.Get("resource_type").(string)
-	resource, err := client.GetResource(resourceType, resourceID)
+	resourceMap, err := b.Expand(ctx, d, m)
 	if err != nil {
-		return err
+		return diag.FromErr(err)
 	}
-
-	if resource == "" {
-		d.SetId("")
-		return nil
+	if err := CreateResource(ctx, d, m.(*client.Client), resourceMap); err != nil {
+		return ErrorDiagnostics(err)
 	}
+	return b.Read(ctx, d, m)
+}
 
-	// Parse the resource JSON
-	var resourceMap map[string]interface{}
-	if err := json.Unmarshal([]byte(resource), &resourceMap); err != nil {
-		return fmt.Errorf("failed to parse resource JSON: %w", err)
+// Update replaces the resource with the body built from its declared fields
+func (b *BaseResource) Update(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	c := m.(*client.Client)
+
+	resourceMap, err := b.Expand(ctx, d, m)
+	if err != nil {
+		return diag.FromErr(err)
 	}
+	resourceMap["id"] = d.Id()
 
-	// Set the meta field if it exists
-	if meta, ok := resourceMap["meta"].(map[string]interface{}); ok {
-		metaList := []map[string]interface{}{make(map[string]interface{})}
-		metaMap := metaList[0]
+	// Convert to JSON
+	resourceJSON, err := json.Marshal(resourceMap)
+	if err != nil {
+		return diag.Errorf("failed to marshal resource: %s", err)
+	}
 
-		// Handle simple string fields
-		if v, ok := meta["version_id"].(string); ok {
-			metaMap["version_id"] = v
-		}
-		if v, ok := meta["last_updated"].(string); ok {
-			metaMap["last_updated"] = v
-		}
-		if v, ok := meta["created_at"].(string); ok {
-			metaMap["created_at"] = v
-		}
+	if err := c.UpdateResource(ctx, b.ResourceType, d.Id(), string(resourceJSON), VersionID(d)); err != nil {
+		return ErrorDiagnostics(err)
+	}
+	return b.Read(ctx, d, m)
+}
 
-		d.Set("meta", metaList)
+// Import accepts either "<resource_id>" or "<ResourceType>/<resource_id>".
+// Resources not bound to a single type require the second form.
+func (b *BaseResource) Import(ctx context.Context, d *schema.ResourceData, m interface{}) ([]*schema.ResourceData, error) {
+	resourceType, resourceID, err := ParseImportID(d.Id(), b.ResourceType)
+	if err != nil {
+		return nil, err
 	}
 
-	// Set resource_type
+	d.SetId(resourceID)
+	d.Set("resource_id", resourceID)
 	d.Set("resource_type", resourceType)
+	// Imported resources are already managed, so the default applies
+	d.Set("adopt_existing", false)
+	return []*schema.ResourceData{d}, nil
+}
 
-	// Set extensions for fields that aren't explicitly defined in the schema
-	extensions := make(map[string]string)
-	for k, v := range resourceMap {
-		// Skip fields that are explicitly defined in the schema
-		if _, ok := d.GetOk(k); ok {
-			continue
-		}
-		// Skip meta and id fields
-		if k == "meta" || k == "id" || k == "resourceType" {
-			continue
-		}
-		// Convert value to string
-		if str, ok := v.(string); ok {
-			extensions[k] = str
-		} else if str, ok := v.(float64); ok {
-			extensions[k] = fmt.Sprintf("%v", str)
-		} else if str, ok := v.(bool); ok {
-			extensions[k] = fmt.Sprintf("%v", str)
-		} else if str, ok := v.([]interface{}); ok {
-			// Handle arrays
-			jsonBytes, err := json.Marshal(str)
-			if err == nil {
-				extensions[k] = string(jsonBytes)
-			}
-		} else if str, ok := v.(map[string]interface{}); ok {
-			// Handle nested objects
-			jsonBytes, err := json.Marshal(str)
-			if err == nil {
-				extensions[k] = string(jsonBytes)
-			}
+// ParseImportID splits an import ID into resource type and ID. When
+// expectedType is set, the type prefix is optional but must match it.
+func ParseImportID(importID, expectedType string) (string, string, error) {
+	resourceType, resourceID := expectedType, importID
+	if i := strings.Index(importID, "/"); i >= 0 {
+		resourceType, resourceID = importID[:i], importID[i+1:]
+		if expectedType != "" && resourceType != expectedType {
+			return "", "", fmt.Errorf("unexpected resource type %q in import ID %q, expected %q", resourceType, importID, expectedType)
 		}
 	}
-	d.Set("extensions", extensions)
 
-	return nil
+	if resourceType == "" || resourceID == "" || strings.Contains(resourceID, "/") {
+		if expectedType == "" {
+			return "", "", fmt.Errorf("invalid import ID %q, expected <ResourceType>/<resource_id>", importID)
+		}
+		return "", "", fmt.Errorf("invalid import ID %q, expected <resource_id> or %s/<resource_id>", importID, expectedType)
+	}
+	return resourceType, resourceID, nil
 }
 
-// ResourceBaseUpdate handles updating an existing Aidbox resource
-func ResourceBaseUpdate(d *schema.ResourceData, m interface{}) error {
-	client := m.(*client.Client)
+// readResource reads the resource and maps it into the common attributes,
+// the declared fields, and extra_json for everything else
+func readResource(ctx context.Context, d *schema.ResourceData, m interface{}, fields []Field) diag.Diagnostics {
+	c := m.(*client.Client)
 
 	resourceID := d.Id()
 	resourceType := d.Get("resource_type").(string)
+	resource, err := c.GetResource(ctx, resourceType, resourceID)
+	if err != nil {
+		// The resource was deleted outside Terraform
+		var notFound *client.NotFoundError
+		if errors.As(err, &notFound) {
+			d.SetId("")
+			return nil
+		}
+		return ErrorDiagnostics(err)
+	}
+
+	// Parse the resource JSON
+	var resourceMap map[string]interface{}
+	if err := json.Unmarshal([]byte(resource), &resourceMap); err != nil {
+		return diag.Errorf("failed to parse resource JSON: %s", err)
+	}
 
-	// Create a map for the resource
-	resourceMap := make(map[string]interface{})
-	resourceMap["resourceType"] = resourceType
-	resourceMap["id"] = resourceID
+	// Set the meta field if it exists
+	SetMeta(d, resourceMap)
 
-	// Add extensions if provided
-	if extensions, ok := d.GetOk("extensions"); ok {
-		extensionsMap := extensions.(map[string]interface{})
-		for k, v := range extensionsMap {
-			resourceMap[k] = v
-		}
+	// Set resource_type and resource_id
+	d.Set("resource_type", resourceType)
+	d.Set("resource_id", resourceID)
+
+	// Set the typed attributes
+	if err := FlattenFields(d, fields, resourceMap); err != nil {
+		return diag.Errorf("failed to read %s/%s: %s", resourceType, resourceID, err)
 	}
 
-	// Convert to JSON
-	resourceJSON, err := json.Marshal(resourceMap)
+	// Set extra_json for fields that aren't explicitly defined in the schema
+	extraJSON, err := FlattenExtraJSON(resourceMap, fieldKeys(fields))
 	if err != nil {
-		return fmt.Errorf("failed to marshal resource: %w", err)
+		return diag.Errorf("failed to encode extra_json: %s", err)
 	}
+	d.Set("extra_json", extraJSON)
+
+	return nil
+}
 
-	if err := client.UpdateResource(resourceType, resourceID, string(resourceJSON)); err != nil {
-		return err
+func isTypedField(k string, typedFields []string) bool {
+	for _, f := range typedFields {
+		if f == k {
+			return true
+		}
 	}
+	return false
+}
 
-	return ResourceBaseRead(d, m)
+// VersionID returns the resource version recorded by the last read. It is
+// sent as If-Match on updates so that concurrent edits are not overwritten.
+func VersionID(d *schema.ResourceData) string {
+	v, _ := d.Get("meta.0.version_id").(string)
+	return v
 }
 
 // ResourceBaseDelete handles deleting an existing Aidbox resource
-func ResourceBaseDelete(d *schema.ResourceData, m interface{}) error {
-	client := m.(*client.Client)
+func ResourceBaseDelete(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	c := m.(*client.Client)
 
 	resourceID := d.Id()
 	resourceType := d.Get("resource_type").(string)
-	return client.DeleteResource(resourceType, resourceID)
+	if err := c.DeleteResource(ctx, resourceType, resourceID); err != nil {
+		// Already gone, nothing left to delete
+		var notFound *client.NotFoundError
+		if errors.As(err, &notFound) {
+			return nil
+		}
+		return ErrorDiagnostics(err)
+	}
+	return nil
 }
@@ -0,0 +1,33 @@
+package resource
+
+import (
+	"testing"
+)
+
+func TestParseImportID(t *testing.T) {
+	cases := []struct {
+		importID, expectedType string
+		resourceType, id       string
+		ok                     bool
+	}{
+		{"admin", "User", "User", "admin", true},
+		{"User/admin", "User", "User", "admin", true},
+		{"Role/admin", "User", "", "", false},
+		{"Organization/org-1", "", "Organization", "org-1", true},
+		{"org-1", "", "", "", false},
+		{"User/", "User", "", "", false},
+		{"User/a/b", "User", "", "", false},
+		{"", "User", "", "", false},
+	}
+
+	for _, c := range cases {
+		resourceType, id, err := ParseImportID(c.importID, c.expectedType)
+		if (err == nil) != c.ok {
+			t.Errorf("ParseImportID(%q, %q): unexpected error %v", c.importID, c.expectedType, err)
+			continue
+		}
+		if resourceType != c.resourceType || id != c.id {
+			t.Errorf("ParseImportID(%q, %q) = %q, %q, want %q, %q", c.importID, c.expectedType, resourceType, id, c.resourceType, c.id)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package resource
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/flawless/terraform-provider-aidbox/internal/client"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+)
+
+// ErrorDiagnostics converts an error into diagnostics. Aidbox errors carrying
+// an OperationOutcome produce one diagnostic per issue.
+func ErrorDiagnostics(err error) diag.Diagnostics {
+	if err == nil {
+		return nil
+	}
+
+	var alreadyExists *AlreadyExistsError
+	if errors.As(err, &alreadyExists) {
+		return diag.Diagnostics{
+			{
+				Severity: diag.Error,
+				Summary:  "Resource already exists",
+				Detail: fmt.Sprintf("%s was not created because Aidbox already has a resource with this ID. "+
+					"Import it to manage it with Terraform, e.g. terraform import <address> %s/%s, "+
+					"or set adopt_existing = true to take it over and overwrite it with this configuration.",
+					err.Error(), alreadyExists.ResourceType, alreadyExists.ID),
+			},
+		}
+	}
+
+	var preconditionFailed *client.PreconditionFailedError
+	if errors.As(err, &preconditionFailed) {
+		return diag.Diagnostics{
+			{
+				Severity: diag.Error,
+				Summary:  "Resource was modified outside Terraform",
+				Detail: "Aidbox rejected the update because the resource changed since Terraform last read it. " +
+					"Run terraform plan again to review the remote changes before applying, " +
+					"or set last_write_wins = true on the provider to overwrite them.\n\n" + err.Error(),
+			},
+		}
+	}
+
+	var apiErr *client.APIError
+	if !errors.As(err, &apiErr) || len(apiErr.Issues) == 0 {
+		return diag.FromErr(err)
+	}
+
+	var diags diag.Diagnostics
+	for _, issue := range apiErr.Issues {
+		severity := diag.Error
+		if issue.Severity == "warning" || issue.Severity == "information" {
+			severity = diag.Warning
+		}
+
+		detail := issue.Diagnostics
+		if issue.Code != "" {
+			detail = fmt.Sprintf("%s\n\nSeverity: %s\nCode: %s", detail, issue.Severity, issue.Code)
+		}
+		if path := issue.Path(); path != "" {
+			detail = fmt.Sprintf("%s\nPath: %s", detail, path)
+		}
+
+		diags = append(diags, diag.Diagnostic{
+			Severity: severity,
+			Summary:  fmt.Sprintf("Aidbox rejected the request while %s (status %d)", apiErr.Operation, apiErr.StatusCode),
+			Detail:   detail,
+		})
+	}
+
+	// Aidbox reported only warnings, but the request still failed
+	if !diags.HasError() {
+		diags = append(diags, diag.FromErr(err)...)
+	}
+	return diags
+}
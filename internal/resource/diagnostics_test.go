@@ -0,0 +1,53 @@
+package resource
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/flawless/terraform-provider-aidbox/internal/client"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+)
+
+func TestErrorDiagnostics(t *testing.T) {
+	if diags := ErrorDiagnostics(nil); diags != nil {
+		t.Fatalf("expected no diagnostics, got %v", diags)
+	}
+
+	diags := ErrorDiagnostics(errors.New("boom"))
+	if len(diags) != 1 || diags[0].Summary != "boom" {
+		t.Fatalf("expected a single plain diagnostic, got %v", diags)
+	}
+
+	err := fmt.Errorf("wrapped: %w", &client.ValidationError{APIError: &client.APIError{
+		Operation:  "creating resource",
+		StatusCode: 422,
+		Issues: []client.Issue{
+			{Severity: "error", Code: "invalid", Diagnostics: "engine is invalid", Expression: []string{"AccessPolicy.engine"}},
+			{Severity: "warning", Code: "informational", Diagnostics: "description is empty"},
+		},
+	}})
+	diags = ErrorDiagnostics(err)
+	if len(diags) != 2 {
+		t.Fatalf("expected one diagnostic per issue, got %d", len(diags))
+	}
+	if diags[0].Severity != diag.Error || !strings.Contains(diags[0].Detail, "Path: AccessPolicy.engine") {
+		t.Errorf("unexpected first diagnostic: %+v", diags[0])
+	}
+	if diags[1].Severity != diag.Warning {
+		t.Errorf("expected warning issue to become a warning diagnostic, got %+v", diags[1])
+	}
+}
+
+func TestErrorDiagnosticsAlreadyExists(t *testing.T) {
+	diags := ErrorDiagnostics(&AlreadyExistsError{ResourceType: "AccessPolicy", ID: "allow-admin"})
+	if len(diags) != 1 || diags[0].Summary != "Resource already exists" {
+		t.Fatalf("expected a single already exists diagnostic, got %v", diags)
+	}
+	for _, want := range []string{"terraform import <address> AccessPolicy/allow-admin", "adopt_existing"} {
+		if !strings.Contains(diags[0].Detail, want) {
+			t.Errorf("expected detail to mention %q, got %q", want, diags[0].Detail)
+		}
+	}
+}
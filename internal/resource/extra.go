@@ -0,0 +1,149 @@
+package resource
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// extraJSONSchema describes the attribute carrying fields that have no typed
+// attribute. Values keep their JSON types, unlike the former extensions map.
+func extraJSONSchema() *schema.Schema {
+	return &schema.Schema{
+		Type:             schema.TypeString,
+		Optional:         true,
+		ValidateFunc:     validateJSONObject,
+		StateFunc:        normalizeJSONStateFunc,
+		DiffSuppressFunc: SuppressEquivalentJSONDiffs,
+		Description:      "JSON object with additional fields that are not explicitly defined in the schema, usually built with jsonencode()",
+	}
+}
+
+// ExpandExtraJSON parses the extra_json attribute into the fields to merge
+// into the resource body
+func ExpandExtraJSON(d *schema.ResourceData) (map[string]interface{}, error) {
+	raw, ok := d.GetOk("extra_json")
+	if !ok {
+		return nil, nil
+	}
+
+	extra := make(map[string]interface{})
+	if err := json.Unmarshal([]byte(raw.(string)), &extra); err != nil {
+		return nil, fmt.Errorf("failed to parse extra_json: %w", err)
+	}
+	return extra, nil
+}
+
+// FlattenExtraJSON encodes the fields of a resource that are neither typed
+// attributes nor managed by Aidbox. It returns an empty string when there are none.
+func FlattenExtraJSON(resourceMap map[string]interface{}, typedFields []string) (string, error) {
+	extra := make(map[string]interface{})
+	for k, v := range resourceMap {
+		// Skip fields that are mapped to typed attributes
+		if isTypedField(k, typedFields) {
+			continue
+		}
+		// Skip meta and id fields
+		if k == "meta" || k == "id" || k == "resourceType" {
+			continue
+		}
+		extra[k] = v
+	}
+
+	if len(extra) == 0 {
+		return "", nil
+	}
+	extraJSON, err := json.Marshal(extra)
+	if err != nil {
+		return "", err
+	}
+	return string(extraJSON), nil
+}
+
+// SuppressEquivalentJSONDiffs suppresses diffs between semantically equal JSON documents
+func SuppressEquivalentJSONDiffs(k, old, new string, d *schema.ResourceData) bool {
+	return JSONEqual(old, new)
+}
+
+// normalizeJSONStateFunc stores JSON attributes with sorted keys and no
+// insignificant whitespace, leaving invalid JSON for validation to report
+func normalizeJSONStateFunc(v interface{}) string {
+	var value interface{}
+	if err := json.Unmarshal([]byte(v.(string)), &value); err != nil {
+		return v.(string)
+	}
+	normalized, err := json.Marshal(value)
+	if err != nil {
+		return v.(string)
+	}
+	return string(normalized)
+}
+
+func validateJSONObject(v interface{}, k string) ([]string, []error) {
+	var object map[string]interface{}
+	if err := json.Unmarshal([]byte(v.(string)), &object); err != nil {
+		return nil, []error{fmt.Errorf("%q must be a JSON object: %w", k, err)}
+	}
+	return nil, nil
+}
+
+// extensionsStateUpgrader migrates state written before extra_json replaced
+// the string-only extensions map. v1 is the state type of schema version 1.
+func extensionsStateUpgrader(v1 cty.Type) schema.StateUpgrader {
+	attributes := v1.AttributeTypes()
+	v0 := make(map[string]cty.Type, len(attributes))
+	for k, v := range attributes {
+		v0[k] = v
+	}
+	if _, ok := v0["extra_json"]; ok {
+		delete(v0, "extra_json")
+		v0["extensions"] = cty.Map(cty.String)
+	}
+
+	return schema.StateUpgrader{
+		Version: 0,
+		Type:    cty.Object(v0),
+		Upgrade: UpgradeExtensionsToExtraJSON,
+	}
+}
+
+// UpgradeExtensionsToExtraJSON converts the extensions map into extra_json.
+// Values holding JSON objects or arrays were stringified by earlier
+// versions and are decoded again; all other values are kept as strings.
+// The next refresh replaces them with the types stored in Aidbox.
+func UpgradeExtensionsToExtraJSON(ctx context.Context, rawState map[string]interface{}, meta interface{}) (map[string]interface{}, error) {
+	extensions, _ := rawState["extensions"].(map[string]interface{})
+	delete(rawState, "extensions")
+	if len(extensions) == 0 {
+		return rawState, nil
+	}
+
+	extra := make(map[string]interface{}, len(extensions))
+	for k, v := range extensions {
+		str, ok := v.(string)
+		if !ok {
+			extra[k] = v
+			continue
+		}
+
+		var decoded interface{}
+		if err := json.Unmarshal([]byte(str), &decoded); err == nil {
+			switch decoded.(type) {
+			case map[string]interface{}, []interface{}:
+				extra[k] = decoded
+				continue
+			}
+		}
+		extra[k] = str
+	}
+
+	extraJSON, err := json.Marshal(extra)
+	if err != nil {
+		return nil, err
+	}
+	rawState["extra_json"] = string(extraJSON)
+	return rawState, nil
+}
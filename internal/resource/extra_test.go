@@ -0,0 +1,69 @@
+package resource
+
+import (
+	"context"
+	"testing"
+)
+
+func TestFlattenExtraJSON(t *testing.T) {
+	resourceMap := map[string]interface{}{
+		"id":           "jane",
+		"resourceType": "User",
+		"meta":         map[string]interface{}{"versionId": "1"},
+		"password":     "$s0$f0801$hash",
+		"active":       true,
+		"loginCount":   float64(3),
+		"data":         map[string]interface{}{"department": "cardiology"},
+	}
+
+	extraJSON, err := FlattenExtraJSON(resourceMap, []string{"password"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Values keep their JSON types and keys are sorted
+	expected := `{"active":true,"data":{"department":"cardiology"},"loginCount":3}`
+	if extraJSON != expected {
+		t.Errorf("expected %s, got %s", expected, extraJSON)
+	}
+
+	empty, err := FlattenExtraJSON(map[string]interface{}{"id": "jane", "resourceType": "User"}, nil)
+	if err != nil || empty != "" {
+		t.Errorf("expected empty extra_json, got %q, %v", empty, err)
+	}
+}
+
+func TestUpgradeExtensionsToExtraJSON(t *testing.T) {
+	rawState := map[string]interface{}{
+		"id": "role-1",
+		"extensions": map[string]interface{}{
+			"description": "Test role",
+			"count":       "3",
+			"links":       `[{"url":"https://example.com"}]`,
+			"nested":      `{"enabled":true}`,
+		},
+	}
+
+	upgraded, err := UpgradeExtensionsToExtraJSON(context.Background(), rawState, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := upgraded["extensions"]; ok {
+		t.Error("extensions should be removed")
+	}
+
+	expected := `{"count":"3","description":"Test role","links":[{"url":"https://example.com"}],"nested":{"enabled":true}}`
+	if got := upgraded["extra_json"]; got != expected {
+		t.Errorf("expected %s, got %v", expected, got)
+	}
+}
+
+func TestUpgradeExtensionsToExtraJSONEmpty(t *testing.T) {
+	upgraded, err := UpgradeExtensionsToExtraJSON(context.Background(), map[string]interface{}{"id": "role-1"}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := upgraded["extra_json"]; ok {
+		t.Error("extra_json should not be set without extensions")
+	}
+}
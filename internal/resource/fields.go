@@ -0,0 +1,414 @@
+package resource
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+// FieldType describes how the JSON value of a field maps to its attribute
+type FieldType int
+
+const (
+	// FieldString is a JSON string
+	FieldString FieldType = iota
+	// FieldBool is a JSON boolean
+	FieldBool
+	// FieldInt is a JSON number without fraction
+	FieldInt
+	// FieldFloat is any JSON number
+	FieldFloat
+	// FieldStringList is a JSON array of strings
+	FieldStringList
+	// FieldStringMap is a JSON object with string values. Other values are
+	// read back encoded as JSON.
+	FieldStringMap
+	// FieldJSON is any JSON value, held in a string attribute and compared
+	// semantically
+	FieldJSON
+	// FieldBlock is a JSON object described by nested fields, held in a
+	// single nested block
+	FieldBlock
+	// FieldBlockList is a JSON array of objects described by nested fields,
+	// held in repeated nested blocks
+	FieldBlockList
+)
+
+// Field maps a Terraform attribute to a JSON path of the Aidbox resource.
+// BaseResource.SetFields derives the schema, the request body, the mapping
+// back on read and the extra_json exclusions from a table of fields.
+type Field struct {
+	// Attribute is the Terraform attribute name
+	Attribute string
+	// Path is the dot-separated JSON path relative to the enclosing object,
+	// e.g. "name.givenName". It defaults to Attribute.
+	Path string
+	Type FieldType
+	// Fields describe the attributes of FieldBlock and FieldBlockList
+	Fields []Field
+
+	Required bool
+	Computed bool
+	ForceNew bool
+	// Default is also used on read when Aidbox omits the field
+	Default   interface{}
+	Sensitive bool
+	// WriteOnly fields are sent to Aidbox but never read back, e.g.
+	// passwords that Aidbox only returns hashed
+	WriteOnly bool
+	// MaxItems limits FieldStringList and FieldBlockList
+	MaxItems int
+	// Deprecated is shown when the attribute is used
+	Deprecated string
+
+	// ValidateFunc checks the value, or each item of a FieldStringList
+	ValidateFunc     schema.SchemaValidateFunc
+	DiffSuppressFunc schema.SchemaDiffSuppressFunc
+	Description      string
+}
+
+func (f Field) path() []string {
+	if f.Path == "" {
+		return []string{f.Attribute}
+	}
+	return strings.Split(f.Path, ".")
+}
+
+// Schema builds the Terraform schema of the field
+func (f Field) Schema() *schema.Schema {
+	s := &schema.Schema{
+		Required:         f.Required,
+		Optional:         !f.Required,
+		Computed:         f.Computed,
+		ForceNew:         f.ForceNew,
+		Default:          f.Default,
+		Sensitive:        f.Sensitive,
+		MaxItems:         f.MaxItems,
+		Deprecated:       f.Deprecated,
+		ValidateFunc:     f.ValidateFunc,
+		DiffSuppressFunc: f.DiffSuppressFunc,
+		Description:      f.Description,
+	}
+
+	switch f.Type {
+	case FieldString:
+		s.Type = schema.TypeString
+	case FieldBool:
+		s.Type = schema.TypeBool
+	case FieldInt:
+		s.Type = schema.TypeInt
+	case FieldFloat:
+		s.Type = schema.TypeFloat
+	case FieldStringList:
+		s.Type = schema.TypeList
+		// Lists are validated item by item
+		s.Elem = &schema.Schema{Type: schema.TypeString, ValidateFunc: f.ValidateFunc}
+		s.ValidateFunc = nil
+	case FieldStringMap:
+		s.Type = schema.TypeMap
+		s.Elem = &schema.Schema{Type: schema.TypeString}
+	case FieldJSON:
+		s.Type = schema.TypeString
+		s.StateFunc = normalizeJSONStateFunc
+		if s.ValidateFunc == nil {
+			s.ValidateFunc = validation.StringIsJSON
+		}
+		if s.DiffSuppressFunc == nil {
+			s.DiffSuppressFunc = SuppressEquivalentJSONDiffs
+		}
+	case FieldBlock:
+		s.Type = schema.TypeList
+		s.MaxItems = 1
+		s.Elem = &schema.Resource{Schema: fieldsSchema(f.Fields)}
+	case FieldBlockList:
+		s.Type = schema.TypeList
+		s.Elem = &schema.Resource{Schema: fieldsSchema(f.Fields)}
+	}
+	return s
+}
+
+func fieldsSchema(fields []Field) map[string]*schema.Schema {
+	result := make(map[string]*schema.Schema, len(fields))
+	for _, f := range fields {
+		result[f.Attribute] = f.Schema()
+	}
+	return result
+}
+
+// ExpandFields builds the JSON object described by fields from the
+// top-level attributes of d. Unset attributes are omitted.
+func ExpandFields(d *schema.ResourceData, fields []Field) (map[string]interface{}, error) {
+	result := make(map[string]interface{})
+	for _, f := range fields {
+		value, ok, err := expandField(f, d.Get(f.Attribute), isConfigured(d, f.Attribute))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f.Attribute, err)
+		}
+		if ok {
+			setPath(result, f.path(), value)
+		}
+	}
+	return result, nil
+}
+
+// FlattenFields sets the top-level attributes of d from the JSON object
+// described by fields. Attributes of fields missing in the object are
+// cleared, so that their removal in Aidbox shows up as drift. Write-only
+// fields keep their value.
+func FlattenFields(d *schema.ResourceData, fields []Field, resourceMap map[string]interface{}) error {
+	for _, f := range fields {
+		if f.WriteOnly {
+			continue
+		}
+		value, ok := getPath(resourceMap, f.path())
+		if err := d.Set(f.Attribute, flattenField(f, value, ok)); err != nil {
+			return fmt.Errorf("%s: %w", f.Attribute, err)
+		}
+	}
+	return nil
+}
+
+// isConfigured reports whether a top-level attribute is set in the
+// configuration, which tells an explicit false or zero from an unset value
+func isConfigured(d *schema.ResourceData, attribute string) bool {
+	raw := d.GetRawConfig()
+	if raw.IsNull() || !raw.IsKnown() || !raw.Type().IsObjectType() || !raw.Type().HasAttribute(attribute) {
+		return false
+	}
+	return !raw.GetAttr(attribute).IsNull()
+}
+
+// expandField converts an attribute value into its JSON value. It reports
+// false when the value is unset and must be omitted; zero values count as
+// unset unless the attribute is configured, required or has a default.
+func expandField(f Field, value interface{}, configured bool) (interface{}, bool, error) {
+	keepZero := configured || f.Required || f.Default != nil
+
+	switch f.Type {
+	case FieldString:
+		s, _ := value.(string)
+		return s, s != "", nil
+	case FieldBool:
+		b, _ := value.(bool)
+		return b, b || keepZero, nil
+	case FieldInt:
+		i, _ := value.(int)
+		return i, i != 0 || keepZero, nil
+	case FieldFloat:
+		n, _ := value.(float64)
+		return n, n != 0 || keepZero, nil
+	case FieldStringList:
+		l, _ := value.([]interface{})
+		return l, len(l) > 0, nil
+	case FieldStringMap:
+		m, _ := value.(map[string]interface{})
+		return m, len(m) > 0, nil
+	case FieldJSON:
+		s, _ := value.(string)
+		if s == "" {
+			return nil, false, nil
+		}
+		var v interface{}
+		if err := json.Unmarshal([]byte(s), &v); err != nil {
+			return nil, false, fmt.Errorf("invalid JSON: %w", err)
+		}
+		return v, true, nil
+	case FieldBlock:
+		l, _ := value.([]interface{})
+		if len(l) == 0 || l[0] == nil {
+			return nil, false, nil
+		}
+		object, err := expandObject(f.Fields, l[0].(map[string]interface{}))
+		return object, err == nil, err
+	case FieldBlockList:
+		l, _ := value.([]interface{})
+		if len(l) == 0 {
+			return nil, false, nil
+		}
+		objects := make([]interface{}, 0, len(l))
+		for _, item := range l {
+			itemMap, _ := item.(map[string]interface{})
+			object, err := expandObject(f.Fields, itemMap)
+			if err != nil {
+				return nil, false, err
+			}
+			objects = append(objects, object)
+		}
+		return objects, true, nil
+	}
+	return nil, false, fmt.Errorf("unsupported field type %d", f.Type)
+}
+
+// expandObject builds the JSON object of a nested block
+func expandObject(fields []Field, values map[string]interface{}) (map[string]interface{}, error) {
+	result := make(map[string]interface{})
+	for _, f := range fields {
+		value, ok, err := expandField(f, values[f.Attribute], false)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f.Attribute, err)
+		}
+		if ok {
+			setPath(result, f.path(), value)
+		}
+	}
+	return result, nil
+}
+
+// flattenField converts a JSON value into its attribute value. Missing
+// values become the field's default, or nil to clear the attribute.
+func flattenField(f Field, value interface{}, ok bool) interface{} {
+	if !ok || value == nil {
+		if f.Default != nil {
+			return f.Default
+		}
+		return zeroValue(f.Type)
+	}
+
+	switch f.Type {
+	case FieldString:
+		return flattenString(value)
+	case FieldBool:
+		b, _ := value.(bool)
+		return b
+	case FieldInt:
+		n, _ := value.(float64)
+		return int(n)
+	case FieldFloat:
+		n, _ := value.(float64)
+		return n
+	case FieldStringList:
+		l, _ := value.([]interface{})
+		return flattenStringList(l)
+	case FieldStringMap:
+		m, _ := value.(map[string]interface{})
+		return flattenStringMap(m)
+	case FieldJSON:
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return nil
+		}
+		return string(encoded)
+	case FieldBlock:
+		m, isObject := value.(map[string]interface{})
+		if !isObject {
+			return nil
+		}
+		return []interface{}{FlattenObject(f.Fields, m)}
+	case FieldBlockList:
+		l, _ := value.([]interface{})
+		result := make([]interface{}, 0, len(l))
+		for _, item := range l {
+			if m, isObject := item.(map[string]interface{}); isObject {
+				result = append(result, FlattenObject(f.Fields, m))
+			}
+		}
+		return result
+	}
+	return nil
+}
+
+// zeroValue returns the value clearing an attribute of the given type. A nil
+// interface would leave maps and lists unchanged.
+func zeroValue(t FieldType) interface{} {
+	switch t {
+	case FieldBool:
+		return false
+	case FieldInt:
+		return 0
+	case FieldFloat:
+		return 0.0
+	case FieldStringList:
+		return []string{}
+	case FieldStringMap:
+		return map[string]string{}
+	case FieldBlock, FieldBlockList:
+		return []interface{}{}
+	}
+	return ""
+}
+
+// FlattenObject converts the JSON object of a nested block into its
+// attributes, e.g. to rebuild nested blocks in a state upgrader
+func FlattenObject(fields []Field, object map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{}, len(fields))
+	for _, f := range fields {
+		if f.WriteOnly {
+			continue
+		}
+		value, ok := getPath(object, f.path())
+		result[f.Attribute] = flattenField(f, value, ok)
+	}
+	return result
+}
+
+// fieldKeys returns the top-level JSON keys covered by fields
+func fieldKeys(fields []Field) []string {
+	keys := make([]string, 0, len(fields))
+	for _, f := range fields {
+		key := f.path()[0]
+		if !isTypedField(key, keys) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+func getPath(object map[string]interface{}, path []string) (interface{}, bool) {
+	var value interface{} = object
+	for _, key := range path {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if value, ok = m[key]; !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+func setPath(object map[string]interface{}, path []string, value interface{}) {
+	for _, key := range path[:len(path)-1] {
+		next, ok := object[key].(map[string]interface{})
+		if !ok {
+			next = make(map[string]interface{})
+			object[key] = next
+		}
+		object = next
+	}
+	object[path[len(path)-1]] = value
+}
+
+// flattenStringMap converts a JSON object into a map of strings, encoding
+// non-string values as JSON
+func flattenStringMap(m map[string]interface{}) map[string]string {
+	result := make(map[string]string, len(m))
+	for k, v := range m {
+		result[k] = flattenString(v)
+	}
+	return result
+}
+
+// flattenStringList converts a JSON array into a list of strings, encoding
+// non-string values as JSON
+func flattenStringList(l []interface{}) []string {
+	result := make([]string, len(l))
+	for i, v := range l {
+		result[i] = flattenString(v)
+	}
+	return result
+}
+
+func flattenString(v interface{}) string {
+	if str, ok := v.(string); ok {
+		return str
+	}
+	jsonBytes, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return string(jsonBytes)
+}
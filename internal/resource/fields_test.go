@@ -0,0 +1,106 @@
+package resource
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+var testFields = []Field{
+	{Attribute: "given_name", Path: "name.givenName", Type: FieldString},
+	{Attribute: "family_name", Path: "name.familyName", Type: FieldString},
+	{Attribute: "active", Type: FieldBool},
+	{Attribute: "age", Type: FieldInt},
+	{Attribute: "secret", Type: FieldString, WriteOnly: true},
+	{Attribute: "data", Type: FieldJSON},
+	{Attribute: "roles", Type: FieldStringList},
+	{
+		Attribute: "telecom",
+		Type:      FieldBlockList,
+		Fields: []Field{
+			{Attribute: "system", Type: FieldString, Default: "phone"},
+			{Attribute: "value", Type: FieldString},
+		},
+	},
+}
+
+func testFieldsResourceData(t *testing.T, raw map[string]interface{}) *schema.ResourceData {
+	t.Helper()
+	d := schema.TestResourceDataRaw(t, fieldsSchema(testFields), raw)
+	d.SetId("test")
+	return d
+}
+
+func TestExpandFields(t *testing.T) {
+	d := testFieldsResourceData(t, map[string]interface{}{
+		"given_name": "Jane",
+		"secret":     "s3cret",
+		"data":       `{"b": 1, "a": [true]}`,
+		"telecom": []interface{}{
+			map[string]interface{}{"value": "+1 555 0100"},
+		},
+	})
+
+	resourceMap, err := ExpandFields(d, testFields)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Unset attributes are omitted; write-only ones are sent
+	expected := map[string]interface{}{
+		"name":   map[string]interface{}{"givenName": "Jane"},
+		"secret": "s3cret",
+		"data":   map[string]interface{}{"a": []interface{}{true}, "b": float64(1)},
+		"telecom": []interface{}{
+			map[string]interface{}{"system": "phone", "value": "+1 555 0100"},
+		},
+	}
+	if !reflect.DeepEqual(resourceMap, expected) {
+		t.Errorf("expected %v, got %v", expected, resourceMap)
+	}
+}
+
+func TestFlattenFields(t *testing.T) {
+	d := testFieldsResourceData(t, map[string]interface{}{
+		"secret": "s3cret",
+		"roles":  []interface{}{"admin"},
+	})
+
+	err := FlattenFields(d, testFields, map[string]interface{}{
+		"name":    map[string]interface{}{"givenName": "Jane", "familyName": "Doe"},
+		"active":  false,
+		"age":     float64(42),
+		"secret":  "$s0$hash",
+		"data":    map[string]interface{}{"b": 1, "a": []interface{}{true}},
+		"telecom": []interface{}{map[string]interface{}{"value": "jane@example.com"}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := map[string]interface{}{
+		"given_name":  "Jane",
+		"family_name": "Doe",
+		"active":      false,
+		"age":         42,
+		// Write-only fields keep the configured value
+		"secret": "s3cret",
+		"data":   `{"a":[true],"b":1}`,
+		// Fields missing in Aidbox are cleared
+		"roles": []interface{}{},
+		// Defaults fill in omitted nested values
+		"telecom": []interface{}{
+			map[string]interface{}{"system": "phone", "value": "jane@example.com"},
+		},
+	}
+	for k, want := range expected {
+		if got := d.Get(k); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: expected %#v, got %#v", k, want, got)
+		}
+	}
+
+	if keys := fieldKeys(testFields); !reflect.DeepEqual(keys, []string{"name", "active", "age", "secret", "data", "roles", "telecom"}) {
+		t.Errorf("unexpected field keys %v", keys)
+	}
+}
@@ -0,0 +1,114 @@
+package resource
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/flawless/terraform-provider-aidbox/internal/client"
+	"github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// idSuffixBytes is the amount of randomness appended to the ID prefix
+const idSuffixBytes = 8
+
+// GenerateID returns a new resource ID for the prefix and uuid strategies
+func GenerateID(strategy client.IDStrategy, prefix string) (string, error) {
+	switch strategy {
+	case client.IDStrategyUUID:
+		return uuid.GenerateUUID()
+	case client.IDStrategyPrefix, "":
+		suffix := make([]byte, idSuffixBytes)
+		if _, err := rand.Read(suffix); err != nil {
+			return "", fmt.Errorf("failed to generate resource ID: %w", err)
+		}
+		return prefix + hex.EncodeToString(suffix), nil
+	}
+	return "", fmt.Errorf("cannot generate resource ID with strategy %q", strategy)
+}
+
+// CreateResource creates resourceMap in Aidbox with a single request. The ID
+// is taken from resource_id or the id in resourceMap; when neither is set it
+// is chosen according to the provider's id_strategy. An existing resource
+// with the same ID is an *AlreadyExistsError unless adopt_existing is set.
+// Once the resource exists its ID is stored in d, so that any later error
+// leaves it tainted.
+func CreateResource(ctx context.Context, d *schema.ResourceData, c *client.Client, resourceMap map[string]interface{}) error {
+	resourceType := resourceMap["resourceType"].(string)
+
+	resourceID := d.Get("resource_id").(string)
+	if resourceID == "" {
+		resourceID, _ = resourceMap["id"].(string)
+	}
+
+	// Let Aidbox assign the ID
+	if resourceID == "" && c.IDStrategy == client.IDStrategyServer {
+		delete(resourceMap, "id")
+		resourceJSON, err := json.Marshal(resourceMap)
+		if err != nil {
+			return fmt.Errorf("failed to marshal resource: %w", err)
+		}
+
+		resourceID, err = c.CreateResourceWithServerID(ctx, resourceType, string(resourceJSON))
+		if err != nil {
+			return err
+		}
+		d.SetId(resourceID)
+		d.Set("resource_id", resourceID)
+		return nil
+	}
+
+	if resourceID == "" {
+		var err error
+		if resourceID, err = GenerateID(c.IDStrategy, c.IDPrefix); err != nil {
+			return err
+		}
+	}
+	resourceMap["id"] = resourceID
+
+	// PUT replaces whatever has the same ID, so only take over existing
+	// resources when asked to
+	if adopt, _ := d.Get("adopt_existing").(bool); !adopt {
+		if err := checkNotExists(ctx, c, resourceType, resourceID); err != nil {
+			return err
+		}
+	}
+
+	resourceJSON, err := json.Marshal(resourceMap)
+	if err != nil {
+		return fmt.Errorf("failed to marshal resource: %w", err)
+	}
+	if err := c.CreateResource(ctx, resourceType, resourceID, string(resourceJSON)); err != nil {
+		return err
+	}
+	d.SetId(resourceID)
+	d.Set("resource_id", resourceID)
+	return nil
+}
+
+// AlreadyExistsError is returned when creating a resource whose ID is taken
+type AlreadyExistsError struct {
+	ResourceType string
+	ID           string
+}
+
+func (e *AlreadyExistsError) Error() string {
+	return fmt.Sprintf("%s/%s already exists in Aidbox", e.ResourceType, e.ID)
+}
+
+// checkNotExists fails with an *AlreadyExistsError if the resource exists
+func checkNotExists(ctx context.Context, c *client.Client, resourceType, resourceID string) error {
+	_, err := c.GetResource(ctx, resourceType, resourceID)
+	if err == nil {
+		return &AlreadyExistsError{ResourceType: resourceType, ID: resourceID}
+	}
+	var notFound *client.NotFoundError
+	if errors.As(err, &notFound) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,40 @@
+package resource
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/flawless/terraform-provider-aidbox/internal/client"
+)
+
+func TestGenerateID(t *testing.T) {
+	cases := []struct {
+		strategy client.IDStrategy
+		prefix   string
+		pattern  string
+	}{
+		{client.IDStrategyPrefix, "tf-", `^tf-[0-9a-f]{16}$`},
+		{client.IDStrategyPrefix, "", `^[0-9a-f]{16}$`},
+		{client.IDStrategyUUID, "ignored-", `^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`},
+	}
+
+	for _, tc := range cases {
+		first, err := GenerateID(tc.strategy, tc.prefix)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tc.strategy, err)
+		}
+		if !regexp.MustCompile(tc.pattern).MatchString(first) {
+			t.Errorf("%s: %q does not match %s", tc.strategy, first, tc.pattern)
+		}
+
+		// Resources created without resource_id must not collide
+		second, _ := GenerateID(tc.strategy, tc.prefix)
+		if first == second {
+			t.Errorf("%s: generated the same ID twice: %q", tc.strategy, first)
+		}
+	}
+
+	if _, err := GenerateID(client.IDStrategyServer, ""); err == nil {
+		t.Error("expected an error for the server strategy")
+	}
+}
@@ -0,0 +1,22 @@
+package resource
+
+import (
+	"encoding/json"
+	"reflect"
+)
+
+// JSONEqual reports whether two JSON documents are semantically equal
+func JSONEqual(a, b string) bool {
+	if a == b {
+		return true
+	}
+
+	var av, bv interface{}
+	if err := json.Unmarshal([]byte(a), &av); err != nil {
+		return false
+	}
+	if err := json.Unmarshal([]byte(b), &bv); err != nil {
+		return false
+	}
+	return reflect.DeepEqual(av, bv)
+}
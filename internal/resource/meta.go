@@ -0,0 +1,184 @@
+package resource
+
+import (
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// createdAtExtensionURL is how Aidbox exposes createdAt through its FHIR API
+const createdAtExtensionURL = "ex:createdAt"
+
+// metaSchema describes the meta block shared by all Aidbox resources. The
+// version and timestamps are set by Aidbox; tags, security labels and
+// profiles can be configured.
+func metaSchema() *schema.Schema {
+	return &schema.Schema{
+		Type:     schema.TypeList,
+		Optional: true,
+		Computed: true,
+		MaxItems: 1,
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"version_id": {
+					Type:     schema.TypeString,
+					Computed: true,
+				},
+				"created_at": {
+					Type:     schema.TypeString,
+					Computed: true,
+				},
+				"last_updated": {
+					Type:     schema.TypeString,
+					Computed: true,
+				},
+				"profile": {
+					Type:        schema.TypeList,
+					Optional:    true,
+					Elem:        &schema.Schema{Type: schema.TypeString},
+					Description: "Canonical URLs of the profiles the resource claims to conform to",
+				},
+				"tag": {
+					Type:        schema.TypeList,
+					Optional:    true,
+					Elem:        codingResource(),
+					Description: "Tags applied to the resource",
+				},
+				"security": {
+					Type:        schema.TypeList,
+					Optional:    true,
+					Elem:        codingResource(),
+					Description: "Security labels applied to the resource",
+				},
+			},
+		},
+	}
+}
+
+// codingResource describes a FHIR Coding
+func codingResource() *schema.Resource {
+	return &schema.Resource{
+		Schema: map[string]*schema.Schema{
+			"system": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			"code": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			"display": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+		},
+	}
+}
+
+// SetMeta copies the meta block of an Aidbox resource into the meta attribute
+func SetMeta(d *schema.ResourceData, resourceMap map[string]interface{}) {
+	meta, ok := resourceMap["meta"].(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	d.Set("meta", FlattenMeta(meta))
+}
+
+// FlattenMeta converts an Aidbox or FHIR meta object into the meta attribute
+func FlattenMeta(meta map[string]interface{}) []interface{} {
+	metaMap := make(map[string]interface{})
+
+	// Handle simple string fields
+	if v, ok := meta["versionId"].(string); ok {
+		metaMap["version_id"] = v
+	}
+	if v, ok := meta["lastUpdated"].(string); ok {
+		metaMap["last_updated"] = v
+	}
+	if v, ok := meta["createdAt"].(string); ok {
+		metaMap["created_at"] = v
+	} else if extensions, ok := meta["extension"].([]interface{}); ok {
+		// The FHIR API moves createdAt into an extension
+		for _, e := range extensions {
+			extension, _ := e.(map[string]interface{})
+			if extension["url"] == createdAtExtensionURL {
+				if v, ok := extension["valueInstant"].(string); ok {
+					metaMap["created_at"] = v
+				}
+			}
+		}
+	}
+
+	if profiles, ok := meta["profile"].([]interface{}); ok {
+		metaMap["profile"] = profiles
+	}
+	if tags, ok := meta["tag"].([]interface{}); ok {
+		metaMap["tag"] = flattenCodings(tags)
+	}
+	if security, ok := meta["security"].([]interface{}); ok {
+		metaMap["security"] = flattenCodings(security)
+	}
+
+	return []interface{}{metaMap}
+}
+
+// ExpandMeta builds the configurable part of the meta object from the meta
+// attribute. It returns nil when there is nothing to send.
+func ExpandMeta(d *schema.ResourceData) map[string]interface{} {
+	metaList, ok := d.Get("meta").([]interface{})
+	if !ok || len(metaList) == 0 || metaList[0] == nil {
+		return nil
+	}
+	metaMap := metaList[0].(map[string]interface{})
+
+	meta := make(map[string]interface{})
+	if profiles, ok := metaMap["profile"].([]interface{}); ok && len(profiles) > 0 {
+		meta["profile"] = profiles
+	}
+	if tags, ok := metaMap["tag"].([]interface{}); ok && len(tags) > 0 {
+		meta["tag"] = expandCodings(tags)
+	}
+	if security, ok := metaMap["security"].([]interface{}); ok && len(security) > 0 {
+		meta["security"] = expandCodings(security)
+	}
+
+	if len(meta) == 0 {
+		return nil
+	}
+	return meta
+}
+
+func flattenCodings(codings []interface{}) []interface{} {
+	result := make([]interface{}, 0, len(codings))
+	for _, c := range codings {
+		coding, ok := c.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		item := make(map[string]interface{})
+		for _, k := range []string{"system", "code", "display"} {
+			if v, ok := coding[k].(string); ok {
+				item[k] = v
+			}
+		}
+		result = append(result, item)
+	}
+	return result
+}
+
+func expandCodings(codings []interface{}) []interface{} {
+	result := make([]interface{}, 0, len(codings))
+	for _, c := range codings {
+		coding, ok := c.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		item := make(map[string]interface{})
+		for _, k := range []string{"system", "code", "display"} {
+			if v, ok := coding[k].(string); ok && v != "" {
+				item[k] = v
+			}
+		}
+		result = append(result, item)
+	}
+	return result
+}
@@ -0,0 +1,93 @@
+package resource
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func loadTestResource(t *testing.T, name string) map[string]interface{} {
+	t.Helper()
+
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", name, err)
+	}
+	var resourceMap map[string]interface{}
+	if err := json.Unmarshal(data, &resourceMap); err != nil {
+		t.Fatalf("failed to parse %s: %v", name, err)
+	}
+	return resourceMap
+}
+
+func testMetaResourceData(t *testing.T) *schema.ResourceData {
+	t.Helper()
+	d := schema.TestResourceDataRaw(t, map[string]*schema.Schema{"meta": metaSchema()}, map[string]interface{}{})
+	d.SetId("test")
+	return d
+}
+
+func TestSetMetaNative(t *testing.T) {
+	d := testMetaResourceData(t)
+	SetMeta(d, loadTestResource(t, "user_native.json"))
+
+	expected := map[string]string{
+		"meta.0.version_id":      "1042",
+		"meta.0.created_at":      "2024-03-11T08:02:13.551212Z",
+		"meta.0.last_updated":    "2024-03-12T10:21:44.190683Z",
+		"meta.0.tag.#":           "1",
+		"meta.0.tag.0.system":    "https://example.com/tags",
+		"meta.0.tag.0.code":      "managed-by-terraform",
+		"meta.0.tag.0.display":   "Managed by Terraform",
+		"meta.0.security.#":      "1",
+		"meta.0.security.0.code": "R",
+	}
+	state := d.State()
+	for k, want := range expected {
+		if got := state.Attributes[k]; got != want {
+			t.Errorf("%s: expected %q, got %q", k, want, got)
+		}
+	}
+}
+
+func TestSetMetaFHIR(t *testing.T) {
+	d := testMetaResourceData(t)
+	SetMeta(d, loadTestResource(t, "patient_fhir.json"))
+
+	expected := map[string]string{
+		"meta.0.version_id":   "1057",
+		"meta.0.created_at":   "2024-03-12T10:25:01.004313Z",
+		"meta.0.last_updated": "2024-03-12T10:25:01.004313Z",
+		"meta.0.profile.#":    "1",
+		"meta.0.profile.0":    "http://hl7.org/fhir/us/core/StructureDefinition/us-core-patient",
+	}
+	state := d.State()
+	for k, want := range expected {
+		if got := state.Attributes[k]; got != want {
+			t.Errorf("%s: expected %q, got %q", k, want, got)
+		}
+	}
+}
+
+func TestExpandMeta(t *testing.T) {
+	d := testMetaResourceData(t)
+	if meta := ExpandMeta(d); meta != nil {
+		t.Fatalf("expected no meta for an empty block, got %v", meta)
+	}
+
+	// Server-managed fields are never sent back
+	native := loadTestResource(t, "user_native.json")
+	SetMeta(d, native)
+
+	expected := map[string]interface{}{
+		"tag":      native["meta"].(map[string]interface{})["tag"],
+		"security": native["meta"].(map[string]interface{})["security"],
+	}
+	if meta := ExpandMeta(d); !reflect.DeepEqual(meta, expected) {
+		t.Errorf("expected %v, got %v", expected, meta)
+	}
+}
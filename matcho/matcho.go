@@ -0,0 +1,318 @@
+// Package matcho evaluates Aidbox Matcho patterns locally, so that matcho
+// access policies can be checked against example requests without a running
+// Aidbox.
+//
+// Patterns and data are decoded JSON values. A pattern matches when:
+//
+//   - an object pattern: every key matches the value of the same key in the
+//     data, which must be an object; other keys of the data are ignored
+//   - an array pattern: every item matches the data item at the same index,
+//     so the data array may be longer
+//   - a string starting with # is a regular expression the data string must
+//     contain a match of, e.g. "#^/Patient/[^/]+$"
+//   - a string starting with . is a path to another field of the data, e.g.
+//     ".user.data.patient_id", whose value the data must equal
+//   - the strings present?, nil? and not-blank? check that the value is
+//     present, absent or a non-empty string
+//   - null matches an absent or null value
+//   - any other value must equal the data; numbers compare by value
+//
+// Object patterns may use the operators $enum (the data equals one of the
+// listed values), $contains (an item of the data array matches the pattern)
+// and $one-of (the data matches one of the listed patterns).
+package matcho
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Mismatch describes a part of the data that does not match the pattern
+type Mismatch struct {
+	// Path is the dot-separated path of the value in the data, e.g.
+	// "params.resource/id" or "body.entry.0"
+	Path     string
+	Expected interface{}
+	Actual   interface{}
+}
+
+func (m Mismatch) String() string {
+	path := m.Path
+	if path == "" {
+		path = "the data"
+	}
+	return fmt.Sprintf("%s: expected %s, got %s", path, describe(m.Expected), describe(m.Actual))
+}
+
+// PatternError reports an invalid pattern, such as a malformed regular
+// expression or an unknown operator
+type PatternError struct {
+	Path string
+	Err  error
+}
+
+func (e *PatternError) Error() string {
+	if e.Path == "" {
+		return fmt.Sprintf("invalid pattern: %s", e.Err)
+	}
+	return fmt.Sprintf("invalid pattern at %s: %s", e.Path, e.Err)
+}
+
+func (e *PatternError) Unwrap() error { return e.Err }
+
+// Match reports whether data matches pattern
+func Match(pattern, data interface{}) (bool, error) {
+	mismatches, err := Mismatches(pattern, data)
+	return len(mismatches) == 0, err
+}
+
+// MatchJSON is Match for JSON documents
+func MatchJSON(pattern, data string) (bool, error) {
+	var p, d interface{}
+	if err := json.Unmarshal([]byte(pattern), &p); err != nil {
+		return false, &PatternError{Err: err}
+	}
+	if err := json.Unmarshal([]byte(data), &d); err != nil {
+		return false, fmt.Errorf("invalid data: %w", err)
+	}
+	return Match(p, d)
+}
+
+// Mismatches returns the parts of data that do not match pattern, with the
+// keys of each object visited in alphabetical order. It is empty when the
+// data matches.
+func Mismatches(pattern, data interface{}) ([]Mismatch, error) {
+	m := &matcher{root: data}
+	if err := m.match(pattern, data, true, nil); err != nil {
+		return nil, err
+	}
+	return m.mismatches, nil
+}
+
+type matcher struct {
+	root       interface{}
+	mismatches []Mismatch
+}
+
+func (m *matcher) fail(path []string, expected, actual interface{}) {
+	m.mismatches = append(m.mismatches, Mismatch{Path: strings.Join(path, "."), Expected: expected, Actual: actual})
+}
+
+// match compares the data at path with the pattern. present tells an absent
+// value from a null one.
+func (m *matcher) match(pattern, data interface{}, present bool, path []string) error {
+	switch p := pattern.(type) {
+	case nil:
+		if data != nil {
+			m.fail(path, nil, data)
+		}
+	case map[string]interface{}:
+		return m.matchObject(p, data, present, path)
+	case []interface{}:
+		items, ok := data.([]interface{})
+		if !ok {
+			m.fail(path, p, data)
+			return nil
+		}
+		for i, item := range p {
+			itemPath := append(path[:len(path):len(path)], strconv.Itoa(i))
+			if i >= len(items) {
+				m.fail(itemPath, item, nil)
+				continue
+			}
+			if err := m.match(item, items[i], true, itemPath); err != nil {
+				return err
+			}
+		}
+	case string:
+		return m.matchString(p, data, present, path)
+	default:
+		if !equal(p, data) {
+			m.fail(path, p, data)
+		}
+	}
+	return nil
+}
+
+func (m *matcher) matchString(p string, data interface{}, present bool, path []string) error {
+	switch {
+	case p == "present?":
+		if !present || data == nil {
+			m.fail(path, p, data)
+		}
+	case p == "nil?":
+		if data != nil {
+			m.fail(path, p, data)
+		}
+	case p == "not-blank?":
+		if s, ok := data.(string); !ok || strings.TrimSpace(s) == "" {
+			m.fail(path, p, data)
+		}
+	case strings.HasPrefix(p, "#"):
+		re, err := regexp.Compile(p[1:])
+		if err != nil {
+			return &PatternError{Path: strings.Join(path, "."), Err: err}
+		}
+		if s, ok := data.(string); !ok || !re.MatchString(s) {
+			m.fail(path, p, data)
+		}
+	case strings.HasPrefix(p, "."):
+		referenced, _ := lookup(m.root, strings.Split(p[1:], "."))
+		if referenced == nil || !equal(referenced, data) {
+			m.fail(path, p, data)
+		}
+	default:
+		if data != p {
+			m.fail(path, p, data)
+		}
+	}
+	return nil
+}
+
+func (m *matcher) matchObject(p map[string]interface{}, data interface{}, present bool, path []string) error {
+	var fields map[string]interface{}
+	for _, key := range sortedKeys(p) {
+		if !strings.HasPrefix(key, "$") {
+			if fields == nil {
+				object, ok := data.(map[string]interface{})
+				if !ok {
+					m.fail(path, p, data)
+					return nil
+				}
+				fields = object
+			}
+			value, ok := fields[key]
+			if err := m.match(p[key], value, ok, append(path[:len(path):len(path)], key)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := m.matchOperator(key, p[key], data, present, path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *matcher) matchOperator(operator string, argument, data interface{}, present bool, path []string) error {
+	operatorPath := strings.Join(append(path[:len(path):len(path)], operator), ".")
+
+	switch operator {
+	case "$enum":
+		values, ok := argument.([]interface{})
+		if !ok {
+			return &PatternError{Path: operatorPath, Err: fmt.Errorf("$enum takes an array of values")}
+		}
+		for _, v := range values {
+			if equal(v, data) {
+				return nil
+			}
+		}
+		m.fail(path, map[string]interface{}{operator: values}, data)
+	case "$contains":
+		items, ok := data.([]interface{})
+		for i := 0; ok && i < len(items); i++ {
+			item := &matcher{root: m.root}
+			if err := item.match(argument, items[i], true, path); err != nil {
+				return err
+			}
+			if len(item.mismatches) == 0 {
+				return nil
+			}
+		}
+		m.fail(path, map[string]interface{}{operator: argument}, data)
+	case "$one-of":
+		patterns, ok := argument.([]interface{})
+		if !ok {
+			return &PatternError{Path: operatorPath, Err: fmt.Errorf("$one-of takes an array of patterns")}
+		}
+		for _, pattern := range patterns {
+			alternative := &matcher{root: m.root}
+			if err := alternative.match(pattern, data, present, path); err != nil {
+				return err
+			}
+			if len(alternative.mismatches) == 0 {
+				return nil
+			}
+		}
+		m.fail(path, map[string]interface{}{operator: patterns}, data)
+	default:
+		return &PatternError{Path: operatorPath, Err: fmt.Errorf("unknown operator %s", operator)}
+	}
+	return nil
+}
+
+// lookup returns the value at path, descending into objects by key and into
+// arrays by index
+func lookup(data interface{}, path []string) (interface{}, bool) {
+	for _, key := range path {
+		switch v := data.(type) {
+		case map[string]interface{}:
+			var ok bool
+			if data, ok = v[key]; !ok {
+				return nil, false
+			}
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, false
+			}
+			data = v[i]
+		default:
+			return nil, false
+		}
+	}
+	return data, true
+}
+
+// equal compares JSON values, treating numbers of any Go type by value
+func equal(a, b interface{}) bool {
+	if x, ok := number(a); ok {
+		y, ok := number(b)
+		return ok && x == y
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func number(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func describe(v interface{}) string {
+	if v == nil {
+		return "nothing"
+	}
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return string(encoded)
+}
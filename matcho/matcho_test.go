@@ -0,0 +1,110 @@
+package matcho
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+const request = `{
+	"uri": "/Patient/pt-1",
+	"request-method": "get",
+	"params": {"resource/type": "Patient", "resource/id": "pt-1"},
+	"headers": {"x-tenant": "north"},
+	"user": {"id": "jane", "data": {"patient_id": "pt-1", "roles": ["nurse", "auditor"]}},
+	"client": {"id": "portal"},
+	"body": {"entry": [{"resourceType": "Patient"}, {"resourceType": "Observation", "value": 42}]}
+}`
+
+func TestMatchJSON(t *testing.T) {
+	cases := []struct {
+		name    string
+		pattern string
+		match   bool
+	}{
+		{"object", `{"uri": "/Patient/pt-1", "request-method": "get"}`, true},
+		{"other value", `{"request-method": "post"}`, false},
+		{"missing key", `{"jwt": {"sub": "jane"}}`, false},
+		{"nested object", `{"user": {"data": {"patient_id": "pt-1"}}}`, true},
+		{"regex", `{"uri": "#^/Patient/[^/]+$"}`, true},
+		{"regex without match", `{"uri": "#^/Observation"}`, false},
+		{"reference", `{"params": {"resource/id": ".user.data.patient_id"}}`, true},
+		{"reference to a different value", `{"user": {"id": ".client.id"}}`, false},
+		{"reference to a missing value", `{"client": {"id": ".jwt.client_id"}}`, false},
+		{"array prefix", `{"user": {"data": {"roles": ["nurse"]}}}`, true},
+		{"array item mismatch", `{"user": {"data": {"roles": ["auditor"]}}}`, false},
+		{"number", `{"body": {"entry": [{}, {"value": 42.0}]}}`, true},
+		{"enum", `{"request-method": {"$enum": ["get", "head"]}}`, true},
+		{"enum without match", `{"request-method": {"$enum": ["post", "put"]}}`, false},
+		{"contains", `{"user": {"data": {"roles": {"$contains": "auditor"}}}}`, true},
+		{"contains pattern", `{"body": {"entry": {"$contains": {"resourceType": "Observation", "value": 42}}}}`, true},
+		{"contains without match", `{"user": {"data": {"roles": {"$contains": "admin"}}}}`, false},
+		{"contains on an object", `{"headers": {"$contains": "north"}}`, false},
+		{"one-of", `{"$one-of": [{"client": {"id": "admin"}}, {"user": {"data": {"roles": {"$contains": "nurse"}}}}]}`, true},
+		{"one-of without match", `{"uri": {"$one-of": ["#^/Observation", "/Patient"]}}`, false},
+		{"present", `{"headers": {"x-tenant": "present?"}}`, true},
+		{"present but missing", `{"headers": {"authorization": "present?"}}`, false},
+		{"nil", `{"jwt": "nil?"}`, true},
+		{"null", `{"client": {"secret": null}}`, true},
+		{"not blank", `{"client": {"id": "not-blank?"}}`, true},
+		{"not blank on a number", `{"body": {"entry": [{}, {"value": "not-blank?"}]}}`, false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			match, err := MatchJSON(tc.pattern, request)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if match != tc.match {
+				t.Errorf("expected match %v, got %v", tc.match, match)
+			}
+		})
+	}
+}
+
+func TestMismatches(t *testing.T) {
+	pattern := map[string]interface{}{
+		"request-method": "post",
+		"params": map[string]interface{}{
+			"resource/type": map[string]interface{}{"$enum": []interface{}{"Observation"}},
+		},
+		"uri": "#^/Patient",
+	}
+	data := map[string]interface{}{
+		"request-method": "get",
+		"params":         map[string]interface{}{"resource/type": "Patient"},
+		"uri":            "/Patient",
+	}
+
+	mismatches, err := Mismatches(pattern, data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{
+		`params.resource/type: expected {"$enum":["Observation"]}, got "Patient"`,
+		`request-method: expected "post", got "get"`,
+	}
+	if len(mismatches) != len(want) {
+		t.Fatalf("expected %d mismatches, got %v", len(want), mismatches)
+	}
+	for i, m := range mismatches {
+		if m.String() != want[i] {
+			t.Errorf("expected %q, got %q", want[i], m.String())
+		}
+	}
+}
+
+func TestInvalidPattern(t *testing.T) {
+	for _, pattern := range []string{
+		`{"uri": "#^/Patient/("}`,
+		`{"uri": {"$like": "/Patient"}}`,
+		`{"uri": {"$enum": "/Patient"}}`,
+	} {
+		_, err := MatchJSON(pattern, request)
+		var patternErr *PatternError
+		if !errors.As(err, &patternErr) || !strings.HasPrefix(patternErr.Path, "uri") {
+			t.Errorf("%s: expected a pattern error at uri, got %v", pattern, err)
+		}
+	}
+}
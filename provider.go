@@ -1,9 +1,16 @@
 package main
 
 import (
+	"context"
+	"fmt"
+	"regexp"
+	"time"
+
 	"github.com/flawless/terraform-provider-aidbox/internal/client"
 	"github.com/flawless/terraform-provider-aidbox/resources"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
 )
 
 func Provider() *schema.Provider {
@@ -25,19 +32,142 @@ func Provider() *schema.Provider {
 				Sensitive:   true,
 				DefaultFunc: schema.EnvDefaultFunc("AIDBOX_CLIENT_SECRET", nil),
 			},
+			"skip_credentials_validation": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("AIDBOX_SKIP_CREDENTIALS_VALIDATION", false),
+				Description: "Skip authenticating against Aidbox when the provider is configured. Credentials are then only checked on the first API call.",
+			},
+			"request_timeout": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "30s",
+				ValidateFunc: validatePositiveDuration,
+				Description:  "Timeout for a single HTTP request to Aidbox; must be greater than zero. Whole operations are bounded by the timeouts block of each resource.",
+			},
+			"last_write_wins": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "Overwrite resources on update even if they were modified in Aidbox since the last read. By default updates send If-Match with the last known versionId and fail on conflict.",
+			},
+			"id_strategy": {
+				Type:     schema.TypeString,
+				Optional: true,
+				Default:  string(client.IDStrategyPrefix),
+				ValidateFunc: validation.StringInSlice([]string{
+					string(client.IDStrategyPrefix),
+					string(client.IDStrategyUUID),
+					string(client.IDStrategyServer),
+				}, false),
+				Description: "How IDs are chosen for resources created without resource_id: prefix (id_prefix followed by a random suffix), uuid (random UUID) or server (assigned by Aidbox)",
+			},
+			"id_prefix": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "tf-",
+				ValidateFunc: validation.StringMatch(regexp.MustCompile(`^[A-Za-z0-9.-]{0,32}$`), "must be at most 32 letters, digits, dashes and dots"),
+				Description:  "Prefix of the IDs generated by the prefix id_strategy",
+			},
+			"retry_max_attempts": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      4,
+				ValidateFunc: validation.IntAtLeast(1),
+				Description:  "Total number of attempts for requests failing with connection errors or 429, 502, 503 and 504 responses",
+			},
+			"retry_min_backoff": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "1s",
+				ValidateFunc: validatePositiveDuration,
+				Description:  "Wait before the first retry, doubled on every following retry",
+			},
+			"retry_max_backoff": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "30s",
+				ValidateFunc: validatePositiveDuration,
+				Description:  "Upper bound for the wait between retries, including waits requested through Retry-After",
+			},
+			"retry_jitter": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     true,
+				Description: "Randomize the wait between retries to spread load on Aidbox",
+			},
 		},
 		ResourcesMap: map[string]*schema.Resource{
-			"aidbox_user":          resources.ResourceAidboxUser(),
-			"aidbox_role":          resources.ResourceAidboxRole(),
-			"aidbox_access_policy": resources.ResourceAidboxAccessPolicy(),
+			"aidbox_user":               resources.ResourceAidboxUser(),
+			"aidbox_role":               resources.ResourceAidboxRole(),
+			"aidbox_access_policy":      resources.ResourceAidboxAccessPolicy(),
+			"aidbox_client":             resources.ResourceAidboxClient(),
+			"aidbox_access_policy_test": resources.ResourceAidboxAccessPolicyTest(),
+			"aidbox_resource":           resources.ResourceAidboxResource(),
 		},
-		ConfigureFunc: func(d *schema.ResourceData) (interface{}, error) {
-			config := &client.Config{
-				URL:          d.Get("url").(string),
-				ClientID:     d.Get("client_id").(string),
-				ClientSecret: d.Get("client_secret").(string),
-			}
-			return client.NewClient(config), nil
+		ConfigureContextFunc: providerConfigure,
+	}
+}
+
+func providerConfigure(ctx context.Context, d *schema.ResourceData) (interface{}, diag.Diagnostics) {
+	config := &client.Config{
+		URL:          d.Get("url").(string),
+		ClientID:     d.Get("client_id").(string),
+		ClientSecret: d.Get("client_secret").(string),
+		Retry: client.RetryConfig{
+			MaxAttempts:   d.Get("retry_max_attempts").(int),
+			DisableJitter: !d.Get("retry_jitter").(bool),
 		},
+		LastWriteWins: d.Get("last_write_wins").(bool),
+		IDStrategy:    client.IDStrategy(d.Get("id_strategy").(string)),
+		IDPrefix:      d.Get("id_prefix").(string),
+	}
+	// Durations are checked by validatePositiveDuration
+	config.RequestTimeout, _ = time.ParseDuration(d.Get("request_timeout").(string))
+	config.Retry.MinBackoff, _ = time.ParseDuration(d.Get("retry_min_backoff").(string))
+	config.Retry.MaxBackoff, _ = time.ParseDuration(d.Get("retry_max_backoff").(string))
+	if config.Retry.MinBackoff > config.Retry.MaxBackoff {
+		return nil, diag.Errorf("retry_min_backoff (%s) must not exceed retry_max_backoff (%s)", config.Retry.MinBackoff, config.Retry.MaxBackoff)
+	}
+	c := client.NewClient(config)
+
+	if d.Get("skip_credentials_validation").(bool) {
+		return c, nil
+	}
+
+	if err := c.ValidateCredentials(ctx); err != nil {
+		return nil, diag.Diagnostics{
+			{
+				Severity: diag.Error,
+				Summary:  "Unable to authenticate with Aidbox",
+				Detail:   "The provider could not acquire an access token from " + config.URL + ": " + err.Error() + "\n\nCheck url, client_id and client_secret, or set skip_credentials_validation to defer authentication to the first API call.",
+			},
+		}
+	}
+
+	return c, nil
+}
+
+// validateDuration checks that a string attribute is a non-negative Go duration such as "500ms" or "1m"
+func validateDuration(v interface{}, k string) ([]string, []error) {
+	d, err := time.ParseDuration(v.(string))
+	if err != nil {
+		return nil, []error{fmt.Errorf("%q must be a duration such as \"500ms\" or \"1m\": %w", k, err)}
+	}
+	if d < 0 {
+		return nil, []error{fmt.Errorf("%q must not be negative", k)}
+	}
+	return nil, nil
+}
+
+// validatePositiveDuration rejects zero as well, for settings where the
+// client would otherwise silently fall back to its default
+func validatePositiveDuration(v interface{}, k string) ([]string, []error) {
+	warnings, errs := validateDuration(v, k)
+	if len(errs) == 0 {
+		if d, _ := time.ParseDuration(v.(string)); d == 0 {
+			errs = append(errs, fmt.Errorf("%q must be greater than zero", k))
+		}
 	}
+	return warnings, errs
 }
@@ -1,10 +1,14 @@
 package main
 
 import (
+	"context"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
 )
 
 var testAccProviders map[string]*schema.Provider
@@ -27,6 +31,39 @@ func TestProvider_impl(t *testing.T) {
 	var _ *schema.Provider = Provider()
 }
 
+func TestProviderConfigure_invalidCredentials(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	p := Provider()
+	diags := p.Configure(context.Background(), terraform.NewResourceConfigRaw(map[string]interface{}{
+		"url":           server.URL,
+		"client_id":     "id",
+		"client_secret": "wrong",
+	}))
+	if !diags.HasError() {
+		t.Fatal("expected an error diagnostic for rejected credentials")
+	}
+}
+
+func TestProviderConfigure_skipCredentialsValidation(t *testing.T) {
+	p := Provider()
+	diags := p.Configure(context.Background(), terraform.NewResourceConfigRaw(map[string]interface{}{
+		"url":                         "http://127.0.0.1:0",
+		"client_id":                   "id",
+		"client_secret":               "secret",
+		"skip_credentials_validation": true,
+	}))
+	if diags.HasError() {
+		t.Fatalf("unexpected error: %v", diags)
+	}
+	if p.Meta() == nil {
+		t.Fatal("expected a configured client")
+	}
+}
+
 func testAccPreCheck(t *testing.T) {
 	if v := os.Getenv("AIDBOX_URL"); v == "" {
 		t.Fatal("AIDBOX_URL must be set for acceptance tests")
@@ -38,3 +75,25 @@ func testAccPreCheck(t *testing.T) {
 		t.Fatal("AIDBOX_CLIENT_SECRET must be set for acceptance tests")
 	}
 }
+
+func TestValidatePositiveDuration(t *testing.T) {
+	for value, valid := range map[string]bool{"30s": true, "500ms": true, "0s": false, "0": false, "-1s": false, "soon": false} {
+		if _, errs := validatePositiveDuration(value, "request_timeout"); (len(errs) == 0) != valid {
+			t.Errorf("%q: expected valid %v, got errors %v", value, valid, errs)
+		}
+	}
+}
+
+func TestProviderValidate_zeroBackoff(t *testing.T) {
+	for _, k := range []string{"retry_min_backoff", "retry_max_backoff"} {
+		diags := Provider().Validate(terraform.NewResourceConfigRaw(map[string]interface{}{
+			"url":           "http://127.0.0.1:0",
+			"client_id":     "id",
+			"client_secret": "secret",
+			k:               "0s",
+		}))
+		if !diags.HasError() {
+			t.Errorf("expected %s = \"0s\" to be rejected", k)
+		}
+	}
+}
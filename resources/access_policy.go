@@ -1,211 +1,529 @@
 package resources
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"slices"
+	"sort"
+	"strings"
 
-	"github.com/flawless/terraform-provider-aidbox/internal/client"
 	"github.com/flawless/terraform-provider-aidbox/internal/resource"
+	"github.com/flawless/terraform-provider-aidbox/matcho"
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/customdiff"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
 )
 
+// subPolicyDepth is how many levels of and/or sub-policies the schema can hold
+const subPolicyDepth = 3
+
+// subPolicyEngines are the engines available to sub-policies
+var subPolicyEngines = []string{"allow", "matcho", "sql", "json-schema", "complex"}
+
 func ResourceAidboxAccessPolicy() *schema.Resource {
 	base := resource.NewBaseResource("AccessPolicy")
 
-	// Add access policy-specific schema fields
-	base.AddSchema("engine", &schema.Schema{
-		Type:     schema.TypeString,
-		Required: true,
-		ValidateFunc: validation.StringInSlice([]string{
-			"json-schema",
-			"allow",
-			"sql",
-			"complex",
-			"matcho",
-			"clj",
-			"matcho-rpc",
-			"allow-rpc",
-			"signed-rpc",
-			"smart-on-fhir",
-		}, false),
-		Description: "The engine for the access policy (json-schema, allow, sql, complex, matcho, clj, matcho-rpc, allow-rpc, signed-rpc, smart-on-fhir)",
-	})
-
-	// Schema field for matcho engine
-	base.AddSchema("matcho", &schema.Schema{
-		Type:        schema.TypeMap,
-		Optional:    true,
-		Description: "Matcho engine configuration",
-		Elem: &schema.Schema{
-			Type: schema.TypeString,
+	// Add access policy-specific fields
+	base.SetFields(
+		resource.Field{
+			Attribute: "engine",
+			Type:      resource.FieldString,
+			Required:  true,
+			ValidateFunc: validation.StringInSlice([]string{
+				"json-schema",
+				"allow",
+				"sql",
+				"complex",
+				"matcho",
+				"clj",
+				"matcho-rpc",
+				"allow-rpc",
+				"signed-rpc",
+				"smart-on-fhir",
+			}, false),
+			Description: "The engine for the access policy (json-schema, allow, sql, complex, matcho, clj, matcho-rpc, allow-rpc, signed-rpc, smart-on-fhir)",
 		},
-	})
+		accessPolicyMatchoField(),
+		accessPolicySQLField(),
+		accessPolicySchemaField(),
+		// Field for RPC engines
+		resource.Field{
+			Attribute:   "rpc",
+			Type:        resource.FieldJSON,
+			Description: "RPC operations the policy allows, mapped to true (allow-rpc) or to a Matcho pattern for their parameters (matcho-rpc), as a JSON document",
+		},
+		// Fields for Complex engine
+		subPoliciesField("and", "Sub-policies of the complex engine that must all allow the request", subPolicyDepth),
+		subPoliciesField("or", "Sub-policies of the complex engine of which one must allow the request", subPolicyDepth),
+		resource.Field{
+			Attribute:   "description",
+			Type:        resource.FieldString,
+			Description: "Human-readable description of the policy",
+		},
+		resource.Field{
+			Attribute: "link",
+			Type:      resource.FieldBlockList,
+			Fields: []resource.Field{
+				{
+					Attribute:    "resource_type",
+					Path:         "resourceType",
+					Type:         resource.FieldString,
+					Required:     true,
+					ValidateFunc: validation.StringInSlice([]string{"User", "Client", "Operation"}, false),
+					Description:  "The type of the linked resource (User, Client, Operation)",
+				},
+				{
+					Attribute:    "id",
+					Type:         resource.FieldString,
+					Required:     true,
+					ValidateFunc: validation.StringIsNotWhiteSpace,
+					Description:  "The ID of the linked resource, e.g. the resource_id of a managed aidbox_client",
+				},
+			},
+			Description: "Users, clients or operations the policy applies to. Aidbox evaluates a linked policy only for requests made by or to them",
+		},
+	)
 
-	// Schema field for SQL engine
-	base.AddSchema("sql", &schema.Schema{
-		Type:        schema.TypeMap,
-		Optional:    true,
-		Description: "SQL engine configuration",
-		Elem: &schema.Schema{
-			Type: schema.TypeString,
+	// Examples are checked at plan time and never sent to Aidbox
+	base.AddSchema("example", &schema.Schema{
+		Type:     schema.TypeList,
+		Optional: true,
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"name": {
+					Type:        schema.TypeString,
+					Optional:    true,
+					Description: "Name of the example in error messages",
+				},
+				"request": {
+					Type:             schema.TypeString,
+					Required:         true,
+					ValidateFunc:     validation.StringIsJSON,
+					DiffSuppressFunc: resource.SuppressEquivalentJSONDiffs,
+					Description:      "The request as the policy engine sees it, e.g. uri, request-method, params, headers, user and client, as a JSON document usually built with jsonencode()",
+				},
+				"expect": {
+					Type:         schema.TypeString,
+					Required:     true,
+					ValidateFunc: validation.StringInSlice([]string{"allow", "deny"}, false),
+					Description:  "The expected outcome, allow or deny",
+				},
+			},
 		},
+		Description: "Example requests checked against the policy at plan time. Only the matcho and allow engines, and complex policies built from them, can be checked",
 	})
 
-	// Schema field for JSON Schema engine
-	base.AddSchema("schema", &schema.Schema{
-		Type:        schema.TypeMap,
-		Optional:    true,
-		Description: "JSON Schema engine configuration",
-		Elem: &schema.Schema{
-			Type: schema.TypeString,
-		},
+	base.SetCustomizeDiff(customdiff.All(validateAccessPolicyEngine, checkAccessPolicyExamples))
+
+	// Version 1 held matcho, sql and schema as string maps
+	base.AddStateUpgrader(schema.StateUpgrader{
+		Version: 1,
+		Type:    accessPolicyStateType(base.Schema, 1),
+		Upgrade: upgradeAccessPolicyEngineFields,
+	})
+	// Version 2 held and and or as lists of JSON strings
+	base.AddStateUpgrader(schema.StateUpgrader{
+		Version: 2,
+		Type:    accessPolicyStateType(base.Schema, 2),
+		Upgrade: upgradeAccessPolicySubPolicies,
 	})
 
-	// Schema field for Complex engine
-	base.AddSchema("and", &schema.Schema{
-		Type:        schema.TypeList,
-		Optional:    true,
-		Description: "Complex engine AND rules",
-		Elem: &schema.Schema{
-			Type: schema.TypeString,
+	return base.ToResource()
+}
+
+func accessPolicyMatchoField() resource.Field {
+	return resource.Field{
+		Attribute:   "matcho",
+		Type:        resource.FieldJSON,
+		Description: "Matcho pattern the request must match, as a JSON document usually built with jsonencode()",
+	}
+}
+
+func accessPolicySQLField() resource.Field {
+	return resource.Field{
+		Attribute: "sql",
+		Type:      resource.FieldBlock,
+		Fields: []resource.Field{
+			{
+				Attribute:    "query",
+				Type:         resource.FieldString,
+				Required:     true,
+				ValidateFunc: validation.StringIsNotWhiteSpace,
+				Description:  "SQL query returning a single boolean; {{...}} placeholders are replaced with request values",
+			},
 		},
-	})
+		Description: "SQL engine configuration",
+	}
+}
 
-	base.AddSchema("or", &schema.Schema{
-		Type:        schema.TypeList,
-		Optional:    true,
-		Description: "Complex engine OR rules",
-		Elem: &schema.Schema{
-			Type: schema.TypeString,
+func accessPolicySchemaField() resource.Field {
+	return resource.Field{
+		Attribute:   "schema",
+		Type:        resource.FieldJSON,
+		Description: "JSON Schema the request must validate against, as a JSON document usually built with jsonencode()",
+	}
+}
+
+// subPoliciesField describes the and/or blocks of a complex policy. Terraform
+// schemas cannot be recursive, so sub-policies nest up to depth levels; the
+// deepest ones cannot use the complex engine.
+func subPoliciesField(attribute, description string, depth int) resource.Field {
+	return resource.Field{
+		Attribute:   attribute,
+		Type:        resource.FieldBlockList,
+		Fields:      subPolicyFields(depth),
+		Description: description,
+	}
+}
+
+func subPolicyFields(depth int) []resource.Field {
+	engines := subPolicyEngines
+	if depth <= 1 {
+		engines = engines[:len(engines)-1]
+	}
+	fields := []resource.Field{
+		{
+			Attribute:    "engine",
+			Type:         resource.FieldString,
+			Required:     true,
+			ValidateFunc: validation.StringInSlice(engines, false),
+			Description:  fmt.Sprintf("The engine of the sub-policy (%s)", strings.Join(engines, ", ")),
 		},
-	})
+		accessPolicyMatchoField(),
+		accessPolicySQLField(),
+		accessPolicySchemaField(),
+	}
+	if depth > 1 {
+		fields = append(fields,
+			subPoliciesField("and", "Nested sub-policies that must all allow the request", depth-1),
+			subPoliciesField("or", "Nested sub-policies of which one must allow the request", depth-1),
+		)
+	}
+	return fields
+}
+
+// accessPolicyEngineFields lists the attributes each engine is configured
+// with; at least one of them must be set. Engines missing from the map take
+// no configuration.
+var accessPolicyEngineFields = map[string][]string{
+	"matcho":      {"matcho"},
+	"sql":         {"sql"},
+	"json-schema": {"schema"},
+	"complex":     {"and", "or"},
+	"matcho-rpc":  {"rpc"},
+	"allow-rpc":   {"rpc"},
+}
+
+// accessPolicyConfigFields are the attributes that configure an engine
+var accessPolicyConfigFields = []string{"matcho", "sql", "schema", "rpc", "and", "or"}
 
-	// Override the create function to handle the access policy-specific fields
-	base.SetCreateFunc(func(d *schema.ResourceData, m interface{}) error {
-		// Set the resource type
-		d.Set("resource_type", "AccessPolicy")
+// validateAccessPolicyEngine checks that the policy and each of its
+// sub-policies set exactly the attributes their engine reads, as Aidbox
+// ignores the others
+func validateAccessPolicyEngine(ctx context.Context, d *schema.ResourceDiff, m interface{}) error {
+	return errors.Join(validatePolicyEngine(d, "", accessPolicyConfigFields, subPolicyDepth)...)
+}
 
-		// Call the base create function to handle common fields
-		if err := resource.ResourceBaseCreate(d, m); err != nil {
-			return err
+// validatePolicyEngine checks the policy whose attributes start with prefix.
+// configFields are the engine attributes it has and depth the levels of
+// sub-policies below it.
+func validatePolicyEngine(d *schema.ResourceDiff, prefix string, configFields []string, depth int) []error {
+	if !d.NewValueKnown(prefix + "engine") {
+		return nil
+	}
+	engine := d.Get(prefix + "engine").(string)
+	fields := accessPolicyEngineFields[engine]
+
+	var errs []error
+	for _, k := range configFields {
+		if accessPolicyFieldSet(d, prefix+k) && !slices.Contains(fields, k) {
+			errs = append(errs, fmt.Errorf("%s%s: not used by engine %q", prefix, k, engine))
 		}
+	}
+
+	// Engines whose attributes are missing at this level are rejected by
+	// the schema
+	available := true
+	configured := false
+	for _, k := range fields {
+		available = available && slices.Contains(configFields, k)
+		configured = configured || (available && accessPolicyFieldSet(d, prefix+k))
+	}
+	if len(fields) > 0 && available && !configured {
+		errs = append(errs, fmt.Errorf("%s%s: required by engine %q", prefix, strings.Join(fields, " or "+prefix), engine))
+	}
 
-		// Get the resource ID
-		resourceID := d.Id()
+	if (engine == "allow-rpc" || engine == "matcho-rpc") && d.NewValueKnown(prefix+"rpc") {
+		errs = append(errs, validateAccessPolicyRPC(engine, d.Get(prefix+"rpc").(string))...)
+	}
 
-		// Create a map for the access policy resource
-		accessPolicyMap := map[string]interface{}{
-			"resourceType": "AccessPolicy",
-			"id":           resourceID,
-			"engine":       d.Get("engine").(string),
+	if depth == 0 {
+		return errs
+	}
+	childFields := []string{"matcho", "sql", "schema"}
+	if depth > 1 {
+		childFields = append(childFields, "and", "or")
+	}
+	for _, k := range []string{"and", "or"} {
+		if !d.NewValueKnown(prefix + k) {
+			continue
 		}
+		for i := range d.Get(prefix + k).([]interface{}) {
+			errs = append(errs, validatePolicyEngine(d, fmt.Sprintf("%s%s.%d.", prefix, k, i), childFields, depth-1)...)
+		}
+	}
+	return errs
+}
 
-		// Handle engine-specific configurations
-		engine := d.Get("engine").(string)
-		switch engine {
-		case "matcho":
-			if v, ok := d.GetOk("matcho"); ok {
-				accessPolicyMap["matcho"] = v
-			}
-		case "sql":
-			if v, ok := d.GetOk("sql"); ok {
-				accessPolicyMap["sql"] = v
-			}
-		case "json-schema":
-			if v, ok := d.GetOk("schema"); ok {
-				accessPolicyMap["schema"] = v
-			}
-		case "complex":
-			if v, ok := d.GetOk("and"); ok {
-				accessPolicyMap["and"] = v
+// validateAccessPolicyRPC checks that allow-rpc maps every operation to true
+// and matcho-rpc maps every operation to a Matcho pattern
+func validateAccessPolicyRPC(engine, rpc string) []error {
+	if rpc == "" {
+		return nil
+	}
+	var operations map[string]interface{}
+	if err := json.Unmarshal([]byte(rpc), &operations); err != nil {
+		return []error{fmt.Errorf("rpc: must be a JSON object of operations: %w", err)}
+	}
+
+	ops := make([]string, 0, len(operations))
+	for op := range operations {
+		ops = append(ops, op)
+	}
+	sort.Strings(ops)
+
+	var errs []error
+	for _, op := range ops {
+		switch v := operations[op].(type) {
+		case bool:
+			if engine == "allow-rpc" && v {
+				continue
 			}
-			if v, ok := d.GetOk("or"); ok {
-				accessPolicyMap["or"] = v
+		case map[string]interface{}:
+			if engine == "matcho-rpc" {
+				continue
 			}
 		}
+		if engine == "allow-rpc" {
+			errs = append(errs, fmt.Errorf("rpc.%s: engine %q requires true", op, engine))
+		} else {
+			errs = append(errs, fmt.Errorf("rpc.%s: engine %q requires a Matcho pattern object", op, engine))
+		}
+	}
+	return errs
+}
 
-		// Add extensions if provided
-		if extensions, ok := d.GetOk("extensions"); ok {
-			extensionsMap := extensions.(map[string]interface{})
-			for k, v := range extensionsMap {
-				accessPolicyMap[k] = v
-			}
+// accessPolicyFieldSet reports whether k is configured. Values unknown at
+// plan time count as configured.
+func accessPolicyFieldSet(d *schema.ResourceDiff, k string) bool {
+	if !d.NewValueKnown(k) {
+		return true
+	}
+	switch v := d.Get(k).(type) {
+	case string:
+		return v != ""
+	case []interface{}:
+		return len(v) > 0
+	}
+	return false
+}
+
+// checkAccessPolicyExamples evaluates the example requests with the local
+// Matcho evaluator and fails the plan when one gets an unexpected outcome
+func checkAccessPolicyExamples(ctx context.Context, d *schema.ResourceDiff, m interface{}) error {
+	if !d.NewValueKnown("example") {
+		return nil
+	}
+
+	var errs []error
+	for i, e := range d.Get("example").([]interface{}) {
+		example := e.(map[string]interface{})
+		name := fmt.Sprintf("example.%d", i)
+		if n, _ := example["name"].(string); n != "" {
+			name = fmt.Sprintf("%s (%s)", name, n)
 		}
 
-		// Convert to JSON
-		accessPolicyJSON, err := json.Marshal(accessPolicyMap)
+		if !d.NewValueKnown(fmt.Sprintf("example.%d.request", i)) {
+			continue
+		}
+		var request interface{}
+		if err := json.Unmarshal([]byte(example["request"].(string)), &request); err != nil {
+			errs = append(errs, fmt.Errorf("%s: invalid request: %w", name, err))
+			continue
+		}
+
+		allowed, reasons, err := evaluateAccessPolicy(d, "", request, subPolicyDepth)
+		if errors.Is(err, errUnknownPolicy) {
+			return nil
+		}
 		if err != nil {
-			return fmt.Errorf("failed to marshal access policy: %w", err)
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			continue
 		}
 
-		// Update the resource with the access policy-specific fields
-		client := m.(*client.Client)
-		if err := client.UpdateResource("AccessPolicy", resourceID, string(accessPolicyJSON)); err != nil {
-			return err
+		switch expect := example["expect"].(string); {
+		case expect == "allow" && !allowed:
+			errs = append(errs, fmt.Errorf("%s: expected the request to be allowed, but the policy denies it:\n%s", name, strings.Join(reasons, "\n")))
+		case expect == "deny" && allowed:
+			errs = append(errs, fmt.Errorf("%s: expected the request to be denied, but the policy allows it", name))
 		}
+	}
+	return errors.Join(errs...)
+}
 
-		return resource.ResourceBaseRead(d, m)
-	})
+// errUnknownPolicy means the policy is not known until apply
+var errUnknownPolicy = errors.New("policy not known at plan time")
 
-	// Override the update function to handle the access policy-specific fields
-	base.SetUpdateFunc(func(d *schema.ResourceData, m interface{}) error {
-		// Get the resource ID
-		resourceID := d.Id()
+// evaluateAccessPolicy reports whether the policy whose attributes start with
+// prefix allows the request, and otherwise why it does not
+func evaluateAccessPolicy(d *schema.ResourceDiff, prefix string, request interface{}, depth int) (bool, []string, error) {
+	if !d.NewValueKnown(prefix + "engine") {
+		return false, nil, errUnknownPolicy
+	}
 
-		// Create a map for the access policy resource
-		accessPolicyMap := map[string]interface{}{
-			"resourceType": "AccessPolicy",
-			"id":           resourceID,
-			"engine":       d.Get("engine").(string),
+	switch engine := d.Get(prefix + "engine").(string); engine {
+	case "allow":
+		return true, nil, nil
+	case "matcho":
+		if !d.NewValueKnown(prefix + "matcho") {
+			return false, nil, errUnknownPolicy
 		}
-
-		// Handle engine-specific configurations
-		engine := d.Get("engine").(string)
-		switch engine {
-		case "matcho":
-			if v, ok := d.GetOk("matcho"); ok {
-				accessPolicyMap["matcho"] = v
-			}
-		case "sql":
-			if v, ok := d.GetOk("sql"); ok {
-				accessPolicyMap["sql"] = v
+		var pattern interface{}
+		if err := json.Unmarshal([]byte(d.Get(prefix+"matcho").(string)), &pattern); err != nil {
+			return false, nil, fmt.Errorf("%smatcho: %w", prefix, err)
+		}
+		mismatches, err := matcho.Mismatches(pattern, request)
+		if err != nil {
+			return false, nil, fmt.Errorf("%smatcho: %w", prefix, err)
+		}
+		reasons := make([]string, len(mismatches))
+		for i, mismatch := range mismatches {
+			reasons[i] = fmt.Sprintf("  %smatcho: %s", prefix, mismatch)
+		}
+		return len(mismatches) == 0, reasons, nil
+	case "complex":
+		if depth == 0 || !d.NewValueKnown(prefix+"and") || !d.NewValueKnown(prefix+"or") {
+			return false, nil, errUnknownPolicy
+		}
+		// Every and sub-policy and one of the or sub-policies must allow
+		var reasons []string
+		for i := range d.Get(prefix + "and").([]interface{}) {
+			allowed, why, err := evaluateAccessPolicy(d, fmt.Sprintf("%sand.%d.", prefix, i), request, depth-1)
+			if err != nil {
+				return false, nil, err
 			}
-		case "json-schema":
-			if v, ok := d.GetOk("schema"); ok {
-				accessPolicyMap["schema"] = v
+			if !allowed {
+				return false, why, nil
 			}
-		case "complex":
-			if v, ok := d.GetOk("and"); ok {
-				accessPolicyMap["and"] = v
+		}
+		or := d.Get(prefix + "or").([]interface{})
+		for i := range or {
+			allowed, why, err := evaluateAccessPolicy(d, fmt.Sprintf("%sor.%d.", prefix, i), request, depth-1)
+			if err != nil {
+				return false, nil, err
 			}
-			if v, ok := d.GetOk("or"); ok {
-				accessPolicyMap["or"] = v
+			if allowed {
+				return true, nil, nil
 			}
+			reasons = append(reasons, why...)
 		}
+		return len(or) == 0, reasons, nil
+	default:
+		return false, nil, fmt.Errorf("the %s engine cannot be checked at plan time", engine)
+	}
+}
 
-		// Add extensions if provided
-		if extensions, ok := d.GetOk("extensions"); ok {
-			extensionsMap := extensions.(map[string]interface{})
-			for k, v := range extensionsMap {
-				accessPolicyMap[k] = v
+// accessPolicyStateType returns the state type of an earlier schema version.
+// Version 2 held and and or as string lists; version 1 also held matcho, sql
+// and schema as string maps and had no rpc.
+func accessPolicyStateType(current map[string]*schema.Schema, version int) cty.Type {
+	earlier := make(map[string]*schema.Schema, len(current))
+	for k, v := range current {
+		earlier[k] = v
+	}
+	for _, k := range []string{"and", "or"} {
+		earlier[k] = &schema.Schema{
+			Type:     schema.TypeList,
+			Optional: true,
+			Elem:     &schema.Schema{Type: schema.TypeString},
+		}
+	}
+	if version == 1 {
+		for _, k := range []string{"matcho", "sql", "schema"} {
+			earlier[k] = &schema.Schema{
+				Type:     schema.TypeMap,
+				Optional: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
 			}
 		}
+		delete(earlier, "rpc")
+	}
+	return (&schema.Resource{Schema: earlier}).CoreConfigSchema().ImpliedType()
+}
 
-		// Convert to JSON
-		accessPolicyJSON, err := json.Marshal(accessPolicyMap)
+// upgradeAccessPolicyEngineFields converts the matcho and schema string maps
+// into JSON documents and the sql map into a sql block. Map values holding
+// JSON objects or arrays were stringified by earlier versions and are
+// decoded again.
+func upgradeAccessPolicyEngineFields(ctx context.Context, rawState map[string]interface{}, meta interface{}) (map[string]interface{}, error) {
+	for _, k := range []string{"matcho", "schema"} {
+		m, _ := rawState[k].(map[string]interface{})
+		if len(m) == 0 {
+			delete(rawState, k)
+			continue
+		}
+		document := make(map[string]interface{}, len(m))
+		for key, v := range m {
+			document[key] = decodeStringifiedJSON(v)
+		}
+		encoded, err := json.Marshal(document)
 		if err != nil {
-			return fmt.Errorf("failed to marshal access policy: %w", err)
+			return nil, err
 		}
+		rawState[k] = string(encoded)
+	}
 
-		// Update the resource with the access policy-specific fields
-		client := m.(*client.Client)
-		if err := client.UpdateResource("AccessPolicy", resourceID, string(accessPolicyJSON)); err != nil {
-			return err
-		}
+	sql, _ := rawState["sql"].(map[string]interface{})
+	if query, ok := sql["query"].(string); ok {
+		rawState["sql"] = []interface{}{map[string]interface{}{"query": query}}
+	} else {
+		delete(rawState, "sql")
+	}
+	return rawState, nil
+}
 
-		return resource.ResourceBaseRead(d, m)
-	})
+// upgradeAccessPolicySubPolicies converts the and and or lists of JSON
+// strings into sub-policy blocks. Items that are not JSON objects could not
+// be applied by Aidbox and are dropped; the next refresh reads them back.
+func upgradeAccessPolicySubPolicies(ctx context.Context, rawState map[string]interface{}, meta interface{}) (map[string]interface{}, error) {
+	fields := subPolicyFields(subPolicyDepth)
+	for _, k := range []string{"and", "or"} {
+		items, _ := rawState[k].([]interface{})
+		subPolicies := make([]interface{}, 0, len(items))
+		for _, item := range items {
+			if object, ok := decodeStringifiedJSON(item).(map[string]interface{}); ok {
+				subPolicies = append(subPolicies, resource.FlattenObject(fields, object))
+			}
+		}
+		rawState[k] = subPolicies
+	}
+	return rawState, nil
+}
 
-	return base.ToResource()
+func decodeStringifiedJSON(v interface{}) interface{} {
+	str, ok := v.(string)
+	if !ok {
+		return v
+	}
+	var decoded interface{}
+	if err := json.Unmarshal([]byte(str), &decoded); err == nil {
+		switch decoded.(type) {
+		case map[string]interface{}, []interface{}:
+			return decoded
+		}
+	}
+	return str
 }
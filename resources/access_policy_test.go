@@ -1,3 +1,419 @@
 package resources
 
-// ... existing code ...
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/flawless/terraform-provider-aidbox/internal/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+func TestResourceAidboxAccessPolicyRead(t *testing.T) {
+	c := newTestClient(t, map[string]string{
+		"/AccessPolicy/policy": `{
+			"resourceType": "AccessPolicy",
+			"id": "policy",
+			"engine": "sql",
+			"sql": {"query": "SELECT true"},
+			"description": "changed in the Aidbox UI",
+			"link": [{"resourceType": "Client", "id": "app"}],
+			"owner": "security"
+		}`,
+	})
+
+	r := ResourceAidboxAccessPolicy()
+	d := r.TestResourceData()
+	d.SetId("policy")
+	d.Set("engine", "matcho")
+	d.Set("matcho", `{"uri":"/Patient"}`)
+
+	if diags := r.ReadContext(context.Background(), d, c); diags.HasError() {
+		t.Fatalf("unexpected error: %v", diags)
+	}
+
+	if got := d.Get("engine"); got != "sql" {
+		t.Errorf("expected engine sql, got %v", got)
+	}
+	if got := d.Get("sql.0.query"); got != "SELECT true" {
+		t.Errorf("expected sql query, got %v", got)
+	}
+	if got := d.Get("matcho"); got != "" {
+		t.Errorf("expected matcho to be cleared, got %v", got)
+	}
+	if got := d.Get("description"); got != "changed in the Aidbox UI" {
+		t.Errorf("expected description, got %v", got)
+	}
+	if got := d.Get("link"); !reflect.DeepEqual(got, []interface{}{
+		map[string]interface{}{"resource_type": "Client", "id": "app"},
+	}) {
+		t.Errorf("unexpected link %v", got)
+	}
+	if got := d.Get("extra_json"); got != `{"owner":"security"}` {
+		t.Errorf("expected owner in extra_json, got %v", got)
+	}
+}
+
+func TestResourceAidboxAccessPolicyCreate(t *testing.T) {
+	c, requests := newRecordingTestClient(t, false)
+
+	r := ResourceAidboxAccessPolicy()
+	d := r.TestResourceData()
+	d.Set("resource_id", "policy")
+	d.Set("engine", "sql")
+	d.Set("sql", []interface{}{map[string]interface{}{"query": "SELECT true"}})
+	d.Set("description", "Own patients only")
+	d.Set("link", []interface{}{map[string]interface{}{"resource_type": "User", "id": "jane"}})
+
+	if diags := r.CreateContext(context.Background(), d, c); diags.HasError() {
+		t.Fatalf("unexpected error: %v", diags)
+	}
+
+	// The policy is written once, complete with its engine configuration
+	var writes []testRequest
+	for _, req := range *requests {
+		if req.Method != http.MethodGet {
+			writes = append(writes, req)
+		}
+	}
+	if len(writes) != 1 {
+		t.Fatalf("expected a single write, got %v", writes)
+	}
+	if !resource.JSONEqual(writes[0].Body, `{"resourceType":"AccessPolicy","id":"policy","engine":"sql","sql":{"query":"SELECT true"},"description":"Own patients only","link":[{"resourceType":"User","id":"jane"}]}`) {
+		t.Errorf("unexpected body %s", writes[0].Body)
+	}
+	if d.Id() != "policy" {
+		t.Errorf("expected id policy, got %q", d.Id())
+	}
+}
+
+func TestResourceAidboxAccessPolicyCreateReadFails(t *testing.T) {
+	c, _ := newRecordingTestClient(t, true)
+
+	r := ResourceAidboxAccessPolicy()
+	d := r.TestResourceData()
+	d.Set("resource_id", "policy")
+	d.Set("engine", "allow")
+
+	if diags := r.CreateContext(context.Background(), d, c); !diags.HasError() {
+		t.Fatal("expected an error")
+	}
+
+	// The policy was created, so it must stay in state to be tainted
+	if d.Id() != "policy" {
+		t.Errorf("expected id policy to be kept, got %q", d.Id())
+	}
+}
+
+func TestResourceAidboxAccessPolicyUpgradeEngineFields(t *testing.T) {
+	rawState := map[string]interface{}{
+		"engine": "complex",
+		"matcho": map[string]interface{}{
+			"uri":            "/Patient",
+			"request-method": "get",
+			"params":         `{"_count":"10"}`,
+		},
+		"sql":    map[string]interface{}{"query": "SELECT true"},
+		"schema": map[string]interface{}{},
+	}
+
+	upgraded, err := upgradeAccessPolicyEngineFields(context.Background(), rawState, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !resource.JSONEqual(upgraded["matcho"].(string), `{"uri":"/Patient","request-method":"get","params":{"_count":"10"}}`) {
+		t.Errorf("unexpected matcho %v", upgraded["matcho"])
+	}
+	if want := []interface{}{map[string]interface{}{"query": "SELECT true"}}; !reflect.DeepEqual(upgraded["sql"], want) {
+		t.Errorf("expected sql %v, got %v", want, upgraded["sql"])
+	}
+	if _, ok := upgraded["schema"]; ok {
+		t.Errorf("expected empty schema to be dropped, got %v", upgraded["schema"])
+	}
+}
+
+func TestResourceAidboxAccessPolicyValidation(t *testing.T) {
+	cases := []struct {
+		name   string
+		config map[string]interface{}
+		err    string
+	}{
+		{
+			name:   "matcho",
+			config: map[string]interface{}{"engine": "matcho", "matcho": `{"uri":"/Patient"}`},
+		},
+		{
+			name: "sql with matcho",
+			config: map[string]interface{}{
+				"engine": "sql",
+				"sql":    []interface{}{map[string]interface{}{"query": "SELECT true"}},
+				"matcho": `{"uri":"/Patient"}`,
+			},
+			err: `matcho: not used by engine "sql"`,
+		},
+		{
+			name:   "missing schema",
+			config: map[string]interface{}{"engine": "json-schema"},
+			err:    `schema: required by engine "json-schema"`,
+		},
+		{
+			name: "complex",
+			config: map[string]interface{}{
+				"engine": "complex",
+				"or": []interface{}{
+					map[string]interface{}{"engine": "matcho", "matcho": `{"uri":"/Patient"}`},
+					map[string]interface{}{
+						"engine": "complex",
+						"and": []interface{}{
+							map[string]interface{}{"engine": "sql", "sql": []interface{}{map[string]interface{}{"query": "SELECT true"}}},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "nested sub-policy with wrong configuration",
+			config: map[string]interface{}{
+				"engine": "complex",
+				"or": []interface{}{
+					map[string]interface{}{
+						"engine": "complex",
+						"and": []interface{}{
+							map[string]interface{}{"engine": "json-schema", "matcho": `{"uri":"/Patient"}`},
+						},
+					},
+				},
+			},
+			err: `or.0.and.0.matcho: not used by engine "json-schema"`,
+		},
+		{
+			name: "complex at the deepest level",
+			config: map[string]interface{}{
+				"engine": "complex",
+				"and": []interface{}{map[string]interface{}{
+					"engine": "complex",
+					"and": []interface{}{map[string]interface{}{
+						"engine": "complex",
+						"and":    []interface{}{map[string]interface{}{"engine": "complex"}},
+					}},
+				}},
+			},
+			err: `expected and.0.and.0.and.0.engine to be one of`,
+		},
+		{
+			name:   "empty complex",
+			config: map[string]interface{}{"engine": "complex"},
+			err:    `and or or: required by engine "complex"`,
+		},
+		{
+			name:   "allow with configuration",
+			config: map[string]interface{}{"engine": "smart-on-fhir", "and": []interface{}{map[string]interface{}{"engine": "allow"}}},
+			err:    `and: not used by engine "smart-on-fhir"`,
+		},
+		{
+			name: "link to a patient",
+			config: map[string]interface{}{
+				"engine": "allow",
+				"link":   []interface{}{map[string]interface{}{"resource_type": "Patient", "id": "pt-1"}},
+			},
+			err: `expected link.0.resource_type to be one of`,
+		},
+		{
+			name:   "allow-rpc",
+			config: map[string]interface{}{"engine": "allow-rpc", "rpc": `{"aidbox.sdc/read-document":true}`},
+		},
+		{
+			name:   "allow-rpc with pattern",
+			config: map[string]interface{}{"engine": "allow-rpc", "rpc": `{"aidbox.sdc/read-document":{"params":{}}}`},
+			err:    `rpc.aidbox.sdc/read-document: engine "allow-rpc" requires true`,
+		},
+		{
+			name:   "matcho-rpc",
+			config: map[string]interface{}{"engine": "matcho-rpc", "rpc": `{"aidbox.sdc/read-document":{"params":{}}}`},
+		},
+	}
+
+	r := ResourceAidboxAccessPolicy()
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			config := terraform.NewResourceConfigRaw(tc.config)
+			var err error
+			if diags := r.Validate(config); diags.HasError() {
+				err = fmt.Errorf("%s", diags[0].Summary)
+			} else {
+				_, err = r.Diff(context.Background(), nil, config, nil)
+			}
+			if tc.err == "" && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tc.err != "" && (err == nil || !strings.Contains(err.Error(), tc.err)) {
+				t.Fatalf("expected error containing %q, got %v", tc.err, err)
+			}
+		})
+	}
+}
+
+func TestResourceAidboxAccessPolicyComplex(t *testing.T) {
+	policy := `{
+		"resourceType": "AccessPolicy",
+		"id": "policy",
+		"engine": "complex",
+		"or": [
+			{"engine": "matcho", "matcho": {"uri": "/Patient", "request-method": "get"}},
+			{"engine": "complex", "and": [
+				{"engine": "sql", "sql": {"query": "SELECT true"}},
+				{"engine": "json-schema", "schema": {"required": ["user"]}}
+			]}
+		]
+	}`
+	c, requests := newRecordingTestClient(t, false)
+	ctx := context.Background()
+	if err := c.CreateResource(ctx, "AccessPolicy", "policy", policy); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	r := ResourceAidboxAccessPolicy()
+	d := r.TestResourceData()
+	d.SetId("policy")
+
+	if diags := r.ReadContext(ctx, d, c); diags.HasError() {
+		t.Fatalf("unexpected error: %v", diags)
+	}
+
+	expected := map[string]interface{}{
+		"or.#":                   2,
+		"or.0.engine":            "matcho",
+		"or.0.matcho":            `{"request-method":"get","uri":"/Patient"}`,
+		"or.1.engine":            "complex",
+		"or.1.and.0.sql.0.query": "SELECT true",
+		"or.1.and.1.engine":      "json-schema",
+		"or.1.and.1.schema":      `{"required":["user"]}`,
+		"extra_json":             "",
+	}
+	for k, want := range expected {
+		if got := d.Get(k); got != want {
+			t.Errorf("%s: expected %v, got %v", k, want, got)
+		}
+	}
+
+	// The sub-policies are written back as they were read
+	if diags := r.UpdateContext(ctx, d, c); diags.HasError() {
+		t.Fatalf("unexpected error: %v", diags)
+	}
+	var body string
+	for _, req := range *requests {
+		if req.Method == http.MethodPut {
+			body = req.Body
+		}
+	}
+	if !resource.JSONEqual(body, policy) {
+		t.Errorf("unexpected body %s", body)
+	}
+}
+
+func TestResourceAidboxAccessPolicyUpgradeSubPolicies(t *testing.T) {
+	rawState := map[string]interface{}{
+		"engine": "complex",
+		"and": []interface{}{
+			`{"engine":"sql","sql":{"query":"SELECT true"}}`,
+			"not a policy",
+		},
+	}
+
+	upgraded, err := upgradeAccessPolicySubPolicies(context.Background(), rawState, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	and, _ := upgraded["and"].([]interface{})
+	if len(and) != 1 {
+		t.Fatalf("expected a single sub-policy, got %v", upgraded["and"])
+	}
+	subPolicy := and[0].(map[string]interface{})
+	if subPolicy["engine"] != "sql" || !reflect.DeepEqual(subPolicy["sql"], []interface{}{map[string]interface{}{"query": "SELECT true"}}) {
+		t.Errorf("unexpected sub-policy %v", subPolicy)
+	}
+	if or, _ := upgraded["or"].([]interface{}); len(or) != 0 {
+		t.Errorf("expected no or sub-policies, got %v", upgraded["or"])
+	}
+}
+
+func TestResourceAidboxAccessPolicyExamples(t *testing.T) {
+	readOwnPatient := map[string]interface{}{
+		"engine": "matcho",
+		"matcho": `{"request-method":"get","uri":"#^/Patient/","params":{"resource/id":".user.data.patient_id"}}`,
+	}
+	ownPatient := `{"request-method":"get","uri":"/Patient/pt-1","params":{"resource/id":"pt-1"},"user":{"data":{"patient_id":"pt-1"}}}`
+	otherPatient := `{"request-method":"get","uri":"/Patient/pt-2","params":{"resource/id":"pt-2"},"user":{"data":{"patient_id":"pt-1"}}}`
+
+	cases := []struct {
+		name     string
+		policy   map[string]interface{}
+		examples []interface{}
+		err      string
+	}{
+		{
+			name:   "matcho",
+			policy: readOwnPatient,
+			examples: []interface{}{
+				map[string]interface{}{"request": ownPatient, "expect": "allow"},
+				map[string]interface{}{"request": otherPatient, "expect": "deny"},
+			},
+		},
+		{
+			name:   "unexpected deny",
+			policy: readOwnPatient,
+			examples: []interface{}{
+				map[string]interface{}{"name": "other patient", "request": otherPatient, "expect": "allow"},
+			},
+			err: `example.0 (other patient): expected the request to be allowed, but the policy denies it:
+  matcho: params.resource/id: expected ".user.data.patient_id", got "pt-2"`,
+		},
+		{
+			name: "complex",
+			policy: map[string]interface{}{
+				"engine": "complex",
+				"or": []interface{}{
+					map[string]interface{}{"engine": "matcho", "matcho": `{"user":{"data":{"roles":{"$contains":"admin"}}}}`},
+					map[string]interface{}{"engine": "matcho", "matcho": readOwnPatient["matcho"]},
+				},
+			},
+			examples: []interface{}{
+				map[string]interface{}{"request": otherPatient, "expect": "allow"},
+			},
+			err: "example.0: expected the request to be allowed",
+		},
+		{
+			name: "sql",
+			policy: map[string]interface{}{
+				"engine": "sql",
+				"sql":    []interface{}{map[string]interface{}{"query": "SELECT true"}},
+			},
+			examples: []interface{}{
+				map[string]interface{}{"request": ownPatient, "expect": "allow"},
+			},
+			err: "the sql engine cannot be checked at plan time",
+		},
+	}
+
+	r := ResourceAidboxAccessPolicy()
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			config := map[string]interface{}{"example": tc.examples}
+			for k, v := range tc.policy {
+				config[k] = v
+			}
+			_, err := r.Diff(context.Background(), nil, terraform.NewResourceConfigRaw(config), nil)
+			if tc.err == "" && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tc.err != "" && (err == nil || !strings.Contains(err.Error(), tc.err)) {
+				t.Fatalf("expected error containing %q, got %v", tc.err, err)
+			}
+		})
+	}
+}
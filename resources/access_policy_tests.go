@@ -0,0 +1,265 @@
+package resources
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/flawless/terraform-provider-aidbox/internal/client"
+	"github.com/flawless/terraform-provider-aidbox/internal/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/id"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+// ResourceAidboxAccessPolicyTest checks sample requests against the access
+// policies in Aidbox when it is created. It manages no Aidbox resource; every
+// attribute forces a new test run.
+func ResourceAidboxAccessPolicyTest() *schema.Resource {
+	return &schema.Resource{
+		Description:   "Evaluates sample requests against the access policies in Aidbox and fails the apply when a request is not allowed or denied as expected",
+		CreateContext: resourceAidboxAccessPolicyTestCreate,
+		ReadContext:   schema.NoopContext,
+		// Nothing is stored in Aidbox, so there is nothing to delete
+		DeleteContext: func(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+			d.SetId("")
+			return nil
+		},
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(20 * time.Minute),
+		},
+		Schema: map[string]*schema.Schema{
+			"policy_ids": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				ForceNew:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString, ValidateFunc: validation.StringIsNotWhiteSpace},
+				Description: "The access policies under test, usually the resource_id of managed aidbox_access_policy resources. Only their results count; all policies in Aidbox count when unset. Policies Aidbox does not know fail the test",
+			},
+			"triggers": {
+				Type:        schema.TypeMap,
+				Optional:    true,
+				ForceNew:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "Arbitrary values that run the tests again when they change, e.g. the versions of the policies under test",
+			},
+			"request": {
+				Type:     schema.TypeList,
+				Required: true,
+				ForceNew: true,
+				MinItems: 1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"name": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							ForceNew:    true,
+							Description: "Name of the request in the report",
+						},
+						"method": {
+							Type:             schema.TypeString,
+							Required:         true,
+							ForceNew:         true,
+							ValidateFunc:     validation.StringInSlice([]string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD", "OPTIONS"}, true),
+							DiffSuppressFunc: func(k, old, new string, d *schema.ResourceData) bool { return strings.EqualFold(old, new) },
+							Description:      "The HTTP method",
+						},
+						"uri": {
+							Type:         schema.TypeString,
+							Required:     true,
+							ForceNew:     true,
+							ValidateFunc: validation.StringMatch(regexp.MustCompile(`^/`), "must start with /"),
+							Description:  "The request URI, including the query string, e.g. /Patient?name=john",
+						},
+						"user_id": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							ForceNew:    true,
+							Description: "The User making the request",
+						},
+						"client_id": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							ForceNew:    true,
+							Description: "The Client making the request",
+						},
+						"headers": {
+							Type:        schema.TypeMap,
+							Optional:    true,
+							ForceNew:    true,
+							Elem:        &schema.Schema{Type: schema.TypeString},
+							Description: "Request headers",
+						},
+						"body": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							ForceNew:     true,
+							ValidateFunc: validation.StringIsJSON,
+							Description:  "The JSON request body, usually built with jsonencode()",
+						},
+						"expect": {
+							Type:         schema.TypeString,
+							Required:     true,
+							ForceNew:     true,
+							ValidateFunc: validation.StringInSlice([]string{"allow", "deny"}, false),
+							Description:  "The expected outcome, allow or deny",
+						},
+					},
+				},
+				Description: "The sample requests and their expected outcome",
+			},
+			"results": {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"name": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"operation": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"allowed": {
+							Type:     schema.TypeBool,
+							Computed: true,
+						},
+						"allowed_by": {
+							Type:     schema.TypeList,
+							Computed: true,
+							Elem:     &schema.Schema{Type: schema.TypeString},
+						},
+					},
+				},
+				Description: "How Aidbox evaluated each request: the operation it was routed to and the policies that allowed it",
+			},
+		},
+	}
+}
+
+func resourceAidboxAccessPolicyTestCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*client.Client)
+	var policyIDs []string
+	for _, v := range d.Get("policy_ids").([]interface{}) {
+		policyIDs = append(policyIDs, v.(string))
+	}
+
+	var results []interface{}
+	var failures []string
+	requests := d.Get("request").([]interface{})
+	for i, r := range requests {
+		r := r.(map[string]interface{})
+		req, err := expandPolicyTestRequest(r)
+		if err != nil {
+			return diag.Errorf("request.%d: %s", i, err)
+		}
+
+		// Aidbox evaluates the request without executing it
+		result, err := client.TestPolicy(ctx, req)
+		if err != nil {
+			return resource.ErrorDiagnostics(fmt.Errorf("request.%d: %w", i, err))
+		}
+
+		// A policy Aidbox did not evaluate could never allow the request,
+		// which would make every expect = "deny" pass
+		if missing := result.Missing(policyIDs); len(missing) > 0 {
+			return diag.Diagnostics{
+				{
+					Severity: diag.Error,
+					Summary:  "Access policies not found",
+					Detail: fmt.Sprintf("Aidbox did not evaluate %s from policy_ids for request.%d. Check the IDs for typos and that the policies exist before the test runs, e.g. by referencing the resource_id of aidbox_access_policy resources.",
+						strings.Join(missing, ", "), i),
+				},
+			}
+		}
+
+		// Only the policies under test count towards the outcome
+		allowed := result.Allowed(policyIDs)
+		var allowedBy []string
+		for _, p := range result.Policies {
+			if p.Allowed && (len(policyIDs) == 0 || slices.Contains(policyIDs, p.PolicyID)) {
+				allowedBy = append(allowedBy, p.PolicyID)
+			}
+		}
+		if expect := r["expect"].(string); (expect == "allow") != allowed {
+			failures = append(failures, describePolicyTestFailure(i, r, expect, result, allowedBy))
+		}
+
+		results = append(results, map[string]interface{}{
+			"name":       r["name"],
+			"operation":  result.Operation,
+			"allowed":    allowed,
+			"allowed_by": allowedBy,
+		})
+	}
+
+	if len(failures) > 0 {
+		return diag.Diagnostics{
+			{
+				Severity: diag.Error,
+				Summary:  "Access policy test failed",
+				Detail: fmt.Sprintf("%d of %d requests did not get the expected outcome:\n\n%s",
+					len(failures), len(requests), strings.Join(failures, "\n")),
+			},
+		}
+	}
+
+	d.SetId(id.UniqueId())
+	if err := d.Set("results", results); err != nil {
+		return diag.FromErr(err)
+	}
+	return nil
+}
+
+func expandPolicyTestRequest(r map[string]interface{}) (client.PolicyTestRequest, error) {
+	req := client.PolicyTestRequest{
+		Method:   r["method"].(string),
+		URI:      r["uri"].(string),
+		UserID:   r["user_id"].(string),
+		ClientID: r["client_id"].(string),
+	}
+	if headers, _ := r["headers"].(map[string]interface{}); len(headers) > 0 {
+		req.Headers = make(map[string]string, len(headers))
+		for k, v := range headers {
+			req.Headers[k] = v.(string)
+		}
+	}
+	if body, _ := r["body"].(string); body != "" {
+		if err := json.Unmarshal([]byte(body), &req.Body); err != nil {
+			return req, fmt.Errorf("invalid JSON body: %w", err)
+		}
+	}
+	return req, nil
+}
+
+// describePolicyTestFailure renders a report line for a request with an
+// unexpected outcome
+func describePolicyTestFailure(i int, r map[string]interface{}, expect string, result *client.PolicyTestResult, allowedBy []string) string {
+	name := fmt.Sprintf("request.%d", i)
+	if n, _ := r["name"].(string); n != "" {
+		name = fmt.Sprintf("%s (%s)", name, n)
+	}
+	line := fmt.Sprintf("- %s: %s %s", name, strings.ToUpper(r["method"].(string)), r["uri"])
+	if userID, _ := r["user_id"].(string); userID != "" {
+		line += " as User/" + userID
+	}
+	if clientID, _ := r["client_id"].(string); clientID != "" {
+		line += " from Client/" + clientID
+	}
+
+	if expect == "allow" {
+		line += " was expected to be allowed, but no policy allowed it"
+	} else {
+		line += " was expected to be denied, but " + strings.Join(allowedBy, ", ") + " allowed it"
+	}
+	if result.Operation != "" {
+		line += fmt.Sprintf(" (operation %s)", result.Operation)
+	}
+	return line
+}
@@ -0,0 +1,82 @@
+package resources
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestResourceAidboxAccessPolicyTestCreate(t *testing.T) {
+	c := newTestClient(t, map[string]string{
+		"/auth/test-policy": `{
+			"operation": {"id": "FhirSearch"},
+			"policies": {
+				"read-patients": {"eval-result": true},
+				"admin": {"eval-result": false}
+			}
+		}`,
+	})
+	r := ResourceAidboxAccessPolicyTest()
+
+	d := r.TestResourceData()
+	d.Set("request", []interface{}{
+		map[string]interface{}{"name": "search patients", "method": "get", "uri": "/Patient", "user_id": "jane", "expect": "allow"},
+	})
+	if diags := r.CreateContext(context.Background(), d, c); diags.HasError() {
+		t.Fatalf("unexpected error: %v", diags)
+	}
+	if d.Id() == "" {
+		t.Error("expected an id")
+	}
+	if got := d.Get("results.0.allowed_by.0"); got != "read-patients" {
+		t.Errorf("expected the request to be allowed by read-patients, got %v", got)
+	}
+	if got := d.Get("results.0.operation"); got != "FhirSearch" {
+		t.Errorf("expected operation FhirSearch, got %v", got)
+	}
+
+	// Only the policies under test count
+	d = r.TestResourceData()
+	d.Set("policy_ids", []interface{}{"admin"})
+	d.Set("request", []interface{}{
+		map[string]interface{}{"method": "GET", "uri": "/Patient", "expect": "deny"},
+	})
+	if diags := r.CreateContext(context.Background(), d, c); diags.HasError() {
+		t.Fatalf("unexpected error: %v", diags)
+	}
+
+	// Policies missing from Aidbox fail the test instead of counting as a deny
+	d = r.TestResourceData()
+	d.Set("policy_ids", []interface{}{"admin", "read-patient", "write-patients"})
+	d.Set("request", []interface{}{
+		map[string]interface{}{"method": "GET", "uri": "/Patient", "expect": "deny"},
+	})
+	diags := r.CreateContext(context.Background(), d, c)
+	if !diags.HasError() || diags[0].Summary != "Access policies not found" {
+		t.Fatalf("expected missing policies to fail the test, got %v", diags)
+	}
+	if !strings.Contains(diags[0].Detail, "read-patient, write-patients from policy_ids for request.0") {
+		t.Errorf("unexpected detail %q", diags[0].Detail)
+	}
+	if d.Id() != "" {
+		t.Errorf("expected no id, got %q", d.Id())
+	}
+
+	// A broken expectation fails with a report
+	d = r.TestResourceData()
+	d.Set("request", []interface{}{
+		map[string]interface{}{"method": "GET", "uri": "/Patient", "expect": "allow"},
+		map[string]interface{}{"name": "anonymous search", "method": "GET", "uri": "/Patient", "expect": "deny"},
+	})
+	diags = r.CreateContext(context.Background(), d, c)
+	if !diags.HasError() || diags[0].Summary != "Access policy test failed" {
+		t.Fatalf("expected the test to fail, got %v", diags)
+	}
+	want := "- request.1 (anonymous search): GET /Patient was expected to be denied, but read-patients allowed it (operation FhirSearch)"
+	if !strings.Contains(diags[0].Detail, "1 of 2 requests") || !strings.Contains(diags[0].Detail, want) {
+		t.Errorf("unexpected report %q", diags[0].Detail)
+	}
+	if d.Id() != "" {
+		t.Errorf("expected no id, got %q", d.Id())
+	}
+}
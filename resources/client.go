@@ -0,0 +1,271 @@
+package resources
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+
+	"github.com/flawless/terraform-provider-aidbox/internal/client"
+	"github.com/flawless/terraform-provider-aidbox/internal/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/customdiff"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+// clientGrantTypes are the OAuth grant types supported by Aidbox
+var clientGrantTypes = []string{
+	"basic",
+	"authorization_code",
+	"code",
+	"password",
+	"client_credentials",
+	"implicit",
+	"refresh_token",
+}
+
+// generatedSecretBytes is the amount of randomness in a generated secret
+const generatedSecretBytes = 32
+
+func ResourceAidboxClient() *schema.Resource {
+	base := resource.NewBaseResource("Client")
+
+	// Add client-specific fields
+	base.SetFields(
+		resource.Field{
+			Attribute: "secret",
+			Type:      resource.FieldString,
+			Computed:  true,
+			Sensitive: true,
+			// Aidbox does not return the secret of every client, so the
+			// configured or generated value is kept
+			WriteOnly:   true,
+			Description: "The client secret. When unset, the secret stored in Aidbox is kept, or generated when generate_secret is set and the client has none",
+		},
+		resource.Field{
+			Attribute:    "grant_types",
+			Type:         resource.FieldStringList,
+			Required:     true,
+			ValidateFunc: validation.StringInSlice(clientGrantTypes, false),
+			Description:  "The grant types the client may use (basic, authorization_code, code, password, client_credentials, implicit, refresh_token)",
+		},
+		resource.Field{
+			Attribute:   "first_party",
+			Type:        resource.FieldBool,
+			Description: "Whether the client is a first-party application that skips the consent screen",
+		},
+		resource.Field{
+			Attribute: "authorization_code",
+			Path:      "auth.authorization_code",
+			Type:      resource.FieldBlock,
+			Fields: append([]resource.Field{
+				redirectURIField(true),
+				{
+					Attribute:   "secret_required",
+					Type:        resource.FieldBool,
+					Description: "Whether the client secret is required to exchange the code",
+				},
+				{
+					Attribute:   "pkce",
+					Type:        resource.FieldBool,
+					Description: "Whether PKCE is required",
+				},
+			}, tokenFields(true)...),
+			Description: "Settings of the authorization_code grant",
+		},
+		resource.Field{
+			Attribute:   "client_credentials",
+			Path:        "auth.client_credentials",
+			Type:        resource.FieldBlock,
+			Fields:      tokenFields(true),
+			Description: "Settings of the client_credentials grant",
+		},
+		resource.Field{
+			Attribute: "password",
+			Path:      "auth.password",
+			Type:      resource.FieldBlock,
+			Fields: append([]resource.Field{
+				redirectURIField(false),
+				{
+					Attribute:   "secret_required",
+					Type:        resource.FieldBool,
+					Description: "Whether the client secret is required with the user's credentials",
+				},
+			}, tokenFields(true)...),
+			Description: "Settings of the password grant",
+		},
+		resource.Field{
+			Attribute:   "implicit",
+			Path:        "auth.implicit",
+			Type:        resource.FieldBlock,
+			Fields:      append([]resource.Field{redirectURIField(true)}, tokenFields(false)...),
+			Description: "Settings of the implicit grant",
+		},
+		resource.Field{
+			Attribute: "smart",
+			Type:      resource.FieldBlock,
+			Fields: []resource.Field{
+				{
+					Attribute:    "launch_uri",
+					Type:         resource.FieldString,
+					ValidateFunc: validation.IsURLWithScheme([]string{"http", "https"}),
+					Description:  "The URL the EHR opens to launch the app",
+				},
+				{
+					Attribute:   "name",
+					Type:        resource.FieldString,
+					Description: "The app name shown to users",
+				},
+				{
+					Attribute:   "description",
+					Type:        resource.FieldString,
+					Description: "The app description shown to users",
+				},
+			},
+			Description: "SMART on FHIR app settings",
+		},
+	)
+
+	base.AddSchema("generate_secret", &schema.Schema{
+		Type:        schema.TypeBool,
+		Optional:    true,
+		Default:     false,
+		Description: "Generate a random secret when secret is not configured and the client has none",
+	})
+
+	base.SetCustomizeDiff(customdiff.All(validateClientGrants, planGeneratedClientSecret))
+	base.SetExpandFunc(expandClientSecret)
+
+	return base.ToResource()
+}
+
+// expandClientSecret completes a body without secret. The body replaces the
+// whole client, so on update the secret stored in Aidbox is carried over,
+// e.g. after import; without one, a secret is generated if requested. The
+// kept or generated secret is recorded in state, as it is never read back.
+func expandClientSecret(ctx context.Context, d *schema.ResourceData, m interface{}, resourceMap map[string]interface{}) error {
+	if _, ok := resourceMap["secret"]; ok {
+		return nil
+	}
+
+	if d.Id() != "" {
+		current, err := m.(*client.Client).GetResource(ctx, "Client", d.Id())
+		if err != nil {
+			return err
+		}
+		var currentMap map[string]interface{}
+		if err := json.Unmarshal([]byte(current), &currentMap); err != nil {
+			return fmt.Errorf("failed to parse resource JSON: %w", err)
+		}
+		if secret, ok := currentMap["secret"].(string); ok && secret != "" {
+			resourceMap["secret"] = secret
+			return d.Set("secret", secret)
+		}
+	}
+
+	if !d.Get("generate_secret").(bool) {
+		return nil
+	}
+	secret, err := generateClientSecret()
+	if err != nil {
+		return err
+	}
+	resourceMap["secret"] = secret
+	return d.Set("secret", secret)
+}
+
+// planGeneratedClientSecret shows the secret as known after apply when one
+// may be generated for an existing client without a secret in state
+func planGeneratedClientSecret(ctx context.Context, d *schema.ResourceDiff, m interface{}) error {
+	if d.Id() == "" || !d.Get("generate_secret").(bool) || !d.NewValueKnown("secret") || d.Get("secret").(string) != "" {
+		return nil
+	}
+	return d.SetNewComputed("secret")
+}
+
+func redirectURIField(required bool) resource.Field {
+	description := "The URI users are redirected to after authorization"
+	if required {
+		description += ". Required when the grant type is enabled"
+	}
+	return resource.Field{
+		Attribute:    "redirect_uri",
+		Type:         resource.FieldString,
+		ValidateFunc: validation.IsURLWithScheme([]string{"http", "https"}),
+		Description:  description,
+	}
+}
+
+// tokenFields describe the token settings shared by the grant types
+func tokenFields(refreshToken bool) []resource.Field {
+	fields := []resource.Field{
+		{
+			Attribute:    "access_token_expiration",
+			Type:         resource.FieldInt,
+			ValidateFunc: validation.IntAtLeast(1),
+			Description:  "Lifetime of access tokens in seconds",
+		},
+		{
+			Attribute:    "token_format",
+			Type:         resource.FieldString,
+			ValidateFunc: validation.StringInSlice([]string{"jwt"}, false),
+			Description:  "Format of the access tokens. Only jwt is supported; opaque tokens are issued when unset",
+		},
+	}
+	if refreshToken {
+		fields = append(fields, resource.Field{
+			Attribute:   "refresh_token",
+			Type:        resource.FieldBool,
+			Description: "Whether a refresh token is issued",
+		})
+	}
+	return fields
+}
+
+// validateClientGrants checks that every grant block belongs to an enabled
+// grant type and that redirect-based grants have a redirect_uri
+func validateClientGrants(ctx context.Context, d *schema.ResourceDiff, m interface{}) error {
+	if !d.NewValueKnown("grant_types") {
+		return nil
+	}
+	enabled := make(map[string]bool)
+	for _, g := range d.Get("grant_types").([]interface{}) {
+		if s, ok := g.(string); ok {
+			enabled[s] = true
+		}
+	}
+
+	for _, grant := range []string{"authorization_code", "client_credentials", "password", "implicit"} {
+		configured := len(d.Get(grant).([]interface{})) > 0
+		if configured && !enabled[grant] {
+			return fmt.Errorf("the %s block requires %q in grant_types", grant, grant)
+		}
+	}
+
+	for _, grant := range []string{"authorization_code", "implicit"} {
+		if !enabled[grant] || !d.NewValueKnown(grant+".0.redirect_uri") {
+			continue
+		}
+		if uri, _ := d.Get(grant + ".0.redirect_uri").(string); uri == "" {
+			return fmt.Errorf("grant type %q requires %s.redirect_uri", grant, grant)
+		}
+	}
+
+	// A generated secret is kept in state once the client exists
+	raw := d.GetRawConfig()
+	secretConfigured := !raw.IsNull() && !raw.GetAttr("secret").IsNull()
+	hasSecret := secretConfigured || d.Get("generate_secret").(bool) || d.Get("secret").(string) != ""
+	if enabled["client_credentials"] && !hasSecret {
+		return fmt.Errorf("grant type %q requires secret or generate_secret", "client_credentials")
+	}
+	return nil
+}
+
+func generateClientSecret() (string, error) {
+	secret := make([]byte, generatedSecretBytes)
+	if _, err := rand.Read(secret); err != nil {
+		return "", fmt.Errorf("failed to generate client secret: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(secret), nil
+}
@@ -1,3 +1,218 @@
 package resources
 
-// ... existing code ...
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+func TestResourceAidboxClientValidation(t *testing.T) {
+	cases := []struct {
+		name   string
+		config map[string]interface{}
+		err    string
+	}{
+		{
+			name: "valid",
+			config: map[string]interface{}{
+				"grant_types": []interface{}{"authorization_code", "client_credentials"},
+				"secret":      "s3cret",
+				"authorization_code": []interface{}{
+					map[string]interface{}{"redirect_uri": "https://app.example.com/callback"},
+				},
+			},
+		},
+		{
+			name: "block without grant type",
+			config: map[string]interface{}{
+				"grant_types": []interface{}{"basic"},
+				"password":    []interface{}{map[string]interface{}{"secret_required": true}},
+			},
+			err: `the password block requires "password" in grant_types`,
+		},
+		{
+			name: "missing redirect_uri",
+			config: map[string]interface{}{
+				"grant_types": []interface{}{"implicit"},
+			},
+			err: "requires implicit.redirect_uri",
+		},
+		{
+			name: "client_credentials without secret",
+			config: map[string]interface{}{
+				"grant_types": []interface{}{"client_credentials"},
+			},
+			err: "requires secret or generate_secret",
+		},
+		{
+			name: "generated secret",
+			config: map[string]interface{}{
+				"grant_types":     []interface{}{"client_credentials"},
+				"generate_secret": true,
+			},
+		},
+	}
+
+	r := ResourceAidboxClient()
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := r.Diff(context.Background(), nil, terraform.NewResourceConfigRaw(tc.config), nil)
+			if tc.err == "" && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tc.err != "" && (err == nil || !strings.Contains(err.Error(), tc.err)) {
+				t.Fatalf("expected error containing %q, got %v", tc.err, err)
+			}
+		})
+	}
+}
+
+func TestResourceAidboxClientCreate(t *testing.T) {
+	c, requests := newRecordingTestClient(t, false)
+
+	r := ResourceAidboxClient()
+	d := r.TestResourceData()
+	d.Set("resource_id", "app")
+	d.Set("generate_secret", true)
+	d.Set("grant_types", []interface{}{"client_credentials"})
+	d.Set("client_credentials", []interface{}{
+		map[string]interface{}{"access_token_expiration": 3600, "token_format": "jwt"},
+	})
+
+	if diags := r.CreateContext(context.Background(), d, c); diags.HasError() {
+		t.Fatalf("unexpected error: %v", diags)
+	}
+
+	secret := d.Get("secret").(string)
+	if len(secret) < 32 {
+		t.Fatalf("expected a generated secret, got %q", secret)
+	}
+
+	var body map[string]interface{}
+	for _, req := range *requests {
+		if req.Method == http.MethodPut {
+			json.Unmarshal([]byte(req.Body), &body)
+		}
+	}
+	if body["secret"] != secret {
+		t.Errorf("expected the generated secret to be sent, got %v", body["secret"])
+	}
+	auth, _ := body["auth"].(map[string]interface{})
+	grant, _ := auth["client_credentials"].(map[string]interface{})
+	if grant["access_token_expiration"] != float64(3600) || grant["token_format"] != "jwt" {
+		t.Errorf("unexpected auth.client_credentials %v", grant)
+	}
+
+	// The secret and grant settings survive the read after create
+	if got := d.Get("client_credentials.0.access_token_expiration"); got != 3600 {
+		t.Errorf("expected access_token_expiration 3600, got %v", got)
+	}
+	if got := d.Get("secret"); got != secret {
+		t.Errorf("expected secret to be kept, got %v", got)
+	}
+}
+
+func TestResourceAidboxClientUpdateKeepsStoredSecret(t *testing.T) {
+	c, requests := newRecordingTestClient(t, false)
+	ctx := context.Background()
+	stored := `{"resourceType":"Client","id":"app","secret":"s3cret","grant_types":["client_credentials"]}`
+	if err := c.CreateResource(ctx, "Client", "app", stored); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Imported clients have no secret in state
+	r := ResourceAidboxClient()
+	d := r.TestResourceData()
+	d.SetId("app")
+	if _, err := r.Importer.StateContext(ctx, d, c); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if diags := r.ReadContext(ctx, d, c); diags.HasError() {
+		t.Fatalf("unexpected error: %v", diags)
+	}
+	if got := d.Get("secret"); got != "" {
+		t.Fatalf("expected no secret in state after import, got %v", got)
+	}
+
+	// An unrelated change must not drop the secret from the full-body PUT
+	d.Set("first_party", true)
+	if diags := r.UpdateContext(ctx, d, c); diags.HasError() {
+		t.Fatalf("unexpected error: %v", diags)
+	}
+
+	var body map[string]interface{}
+	for _, req := range *requests {
+		if req.Method == http.MethodPut {
+			json.Unmarshal([]byte(req.Body), &body)
+		}
+	}
+	if body["secret"] != "s3cret" || body["first_party"] != true {
+		t.Errorf("expected the stored secret to be kept, got %v", body)
+	}
+	if got := d.Get("secret"); got != "s3cret" {
+		t.Errorf("expected the kept secret in state, got %v", got)
+	}
+}
+
+func TestResourceAidboxClientUpdateGeneratesSecret(t *testing.T) {
+	c, requests := newRecordingTestClient(t, false)
+	ctx := context.Background()
+	if err := c.CreateResource(ctx, "Client", "app", `{"resourceType":"Client","id":"app","grant_types":["basic"]}`); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	r := ResourceAidboxClient()
+	d := r.TestResourceData()
+	d.SetId("app")
+	d.Set("resource_type", "Client")
+	d.Set("grant_types", []interface{}{"basic"})
+	d.Set("generate_secret", true)
+	if diags := r.UpdateContext(ctx, d, c); diags.HasError() {
+		t.Fatalf("unexpected error: %v", diags)
+	}
+
+	secret := d.Get("secret").(string)
+	if len(secret) < 32 {
+		t.Fatalf("expected a generated secret, got %q", secret)
+	}
+	var body map[string]interface{}
+	for _, req := range *requests {
+		if req.Method == http.MethodPut {
+			json.Unmarshal([]byte(req.Body), &body)
+		}
+	}
+	if body["secret"] != secret {
+		t.Errorf("expected the generated secret to be sent, got %v", body["secret"])
+	}
+}
+
+func TestResourceAidboxClientPlansGeneratedSecret(t *testing.T) {
+	r := ResourceAidboxClient()
+	state := &terraform.InstanceState{
+		ID: "app",
+		Attributes: map[string]string{
+			"id":              "app",
+			"resource_id":     "app",
+			"grant_types.#":   "1",
+			"grant_types.0":   "basic",
+			"generate_secret": "false",
+		},
+	}
+	config := terraform.NewResourceConfigRaw(map[string]interface{}{
+		"resource_id":     "app",
+		"grant_types":     []interface{}{"basic"},
+		"generate_secret": true,
+	})
+
+	diff, err := r.Diff(context.Background(), state, config, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if attr := diff.Attributes["secret"]; attr == nil || !attr.NewComputed {
+		t.Errorf("expected secret to be known after apply, got %+v", attr)
+	}
+}
@@ -0,0 +1,205 @@
+package resources
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/flawless/terraform-provider-aidbox/internal/client"
+	"github.com/flawless/terraform-provider-aidbox/internal/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+// serverManagedKeys are the top-level keys Aidbox owns on every resource.
+// They are stripped from the resource body before it is stored or compared.
+var serverManagedKeys = []string{"id", "resourceType", "meta"}
+
+func ResourceAidboxResource() *schema.Resource {
+	base := resource.NewBaseResource("")
+
+	// The generic resource carries its whole body in the resource attribute
+	base.RemoveSchema("extra_json")
+
+	base.AddSchema("resource_type", &schema.Schema{
+		Type:         schema.TypeString,
+		Required:     true,
+		ForceNew:     true,
+		ValidateFunc: validation.StringIsNotEmpty,
+		Description:  "The Aidbox resource type, e.g. Organization or SearchParameter",
+	})
+
+	base.AddSchema("resource", &schema.Schema{
+		Type:             schema.TypeString,
+		Required:         true,
+		ValidateFunc:     validation.StringIsJSON,
+		StateFunc:        normalizeResourceBodyStateFunc,
+		DiffSuppressFunc: suppressEquivalentResourceBodyDiffs,
+		Description:      "The JSON body of the resource, usually built with jsonencode()",
+	})
+
+	base.SetCreateFunc(func(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+		resourceType := d.Get("resource_type").(string)
+
+		// Parse the resource body
+		resourceMap, err := expandResourceBody(d.Get("resource").(string))
+		if err != nil {
+			return diag.FromErr(err)
+		}
+
+		// Resolve the resource ID from resource_id or the body itself. When
+		// neither is set, the provider's id_strategy chooses it.
+		resourceID := d.Get("resource_id").(string)
+		if bodyID, ok := resourceMap["id"].(string); ok && bodyID != "" {
+			if resourceID != "" && resourceID != bodyID {
+				return diag.Errorf("resource_id %q does not match id %q in the resource body", resourceID, bodyID)
+			}
+			resourceID = bodyID
+		}
+
+		resourceMap["resourceType"] = resourceType
+		if resourceID != "" {
+			resourceMap["id"] = resourceID
+		}
+
+		// Add configurable meta
+		if meta := resource.ExpandMeta(d); meta != nil {
+			resourceMap["meta"] = meta
+		}
+
+		// Create the resource
+		client := m.(*client.Client)
+		if err := resource.CreateResource(ctx, d, client, resourceMap); err != nil {
+			return resource.ErrorDiagnostics(err)
+		}
+
+		return resourceAidboxResourceRead(ctx, d, m)
+	})
+
+	base.SetReadFunc(resourceAidboxResourceRead)
+
+	base.SetUpdateFunc(func(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+		resourceID := d.Id()
+		resourceType := d.Get("resource_type").(string)
+
+		// Parse the resource body
+		resourceMap, err := expandResourceBody(d.Get("resource").(string))
+		if err != nil {
+			return diag.FromErr(err)
+		}
+		resourceMap["resourceType"] = resourceType
+		resourceMap["id"] = resourceID
+
+		// Add configurable meta
+		if meta := resource.ExpandMeta(d); meta != nil {
+			resourceMap["meta"] = meta
+		}
+
+		// Convert to JSON
+		resourceJSON, err := json.Marshal(resourceMap)
+		if err != nil {
+			return diag.Errorf("failed to marshal resource: %s", err)
+		}
+
+		// Update the resource
+		client := m.(*client.Client)
+		if err := client.UpdateResource(ctx, resourceType, resourceID, string(resourceJSON), resource.VersionID(d)); err != nil {
+			return resource.ErrorDiagnostics(err)
+		}
+
+		return resourceAidboxResourceRead(ctx, d, m)
+	})
+
+	return base.ToResource()
+}
+
+// resourceAidboxResourceRead refreshes the resource body from Aidbox so that
+// any change made outside Terraform shows up as drift
+func resourceAidboxResourceRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	c := m.(*client.Client)
+
+	resourceID := d.Id()
+	resourceType := d.Get("resource_type").(string)
+	body, err := c.GetResource(ctx, resourceType, resourceID)
+	if err != nil {
+		// The resource was deleted outside Terraform
+		var notFound *client.NotFoundError
+		if errors.As(err, &notFound) {
+			d.SetId("")
+			return nil
+		}
+		return resource.ErrorDiagnostics(err)
+	}
+
+	// Parse the resource JSON
+	var resourceMap map[string]interface{}
+	if err := json.Unmarshal([]byte(body), &resourceMap); err != nil {
+		return diag.Errorf("failed to parse resource JSON: %s", err)
+	}
+
+	resource.SetMeta(d, resourceMap)
+
+	// Store the body without the server-managed keys
+	for _, k := range serverManagedKeys {
+		delete(resourceMap, k)
+	}
+	resourceJSON, err := json.Marshal(resourceMap)
+	if err != nil {
+		return diag.Errorf("failed to marshal resource: %s", err)
+	}
+
+	d.Set("resource_id", resourceID)
+	d.Set("resource_type", resourceType)
+	d.Set("resource", string(resourceJSON))
+
+	return nil
+}
+
+// expandResourceBody parses the resource attribute into a map
+func expandResourceBody(body string) (map[string]interface{}, error) {
+	resourceMap := make(map[string]interface{})
+	if err := json.Unmarshal([]byte(body), &resourceMap); err != nil {
+		return nil, fmt.Errorf("failed to parse resource JSON: %w", err)
+	}
+	return resourceMap, nil
+}
+
+// normalizeResourceBody returns the resource body without server-managed
+// keys, re-encoded in a canonical form
+func normalizeResourceBody(body string) (string, error) {
+	resourceMap, err := expandResourceBody(body)
+	if err != nil {
+		return "", err
+	}
+	for _, k := range serverManagedKeys {
+		delete(resourceMap, k)
+	}
+
+	normalized, err := json.Marshal(resourceMap)
+	if err != nil {
+		return "", err
+	}
+	return string(normalized), nil
+}
+
+func normalizeResourceBodyStateFunc(v interface{}) string {
+	normalized, err := normalizeResourceBody(v.(string))
+	if err != nil {
+		return v.(string)
+	}
+	return normalized
+}
+
+func suppressEquivalentResourceBodyDiffs(k, old, new string, d *schema.ResourceData) bool {
+	oldNormalized, err := normalizeResourceBody(old)
+	if err != nil {
+		return false
+	}
+	newNormalized, err := normalizeResourceBody(new)
+	if err != nil {
+		return false
+	}
+	return resource.JSONEqual(oldNormalized, newNormalized)
+}
@@ -0,0 +1,54 @@
+package resources
+
+import (
+	"testing"
+)
+
+func TestResourceAidboxResource(t *testing.T) {
+	resource := ResourceAidboxResource()
+	if resource == nil {
+		t.Fatal("resource is nil")
+	}
+
+	// Test schema
+	schema := resource.Schema
+	if schema == nil {
+		t.Fatal("schema is nil")
+	}
+
+	// Test required fields
+	requiredFields := []string{"resource_type", "resource"}
+	for _, field := range requiredFields {
+		if schema[field] == nil {
+			t.Errorf("required field %s is missing", field)
+		}
+		if !schema[field].Required {
+			t.Errorf("field %s should be required", field)
+		}
+	}
+
+	// The whole body lives in the resource attribute
+	if schema["extra_json"] != nil {
+		t.Error("field extra_json should not be defined")
+	}
+}
+
+func TestResourceAidboxResourceBodyDiff(t *testing.T) {
+	cases := []struct {
+		old, new string
+		equal    bool
+	}{
+		{`{"name":"Org","active":true}`, `{"active": true, "name": "Org"}`, true},
+		{`{"name":"Org"}`, `{"id":"org-1","resourceType":"Organization","name":"Org"}`, true},
+		{`{"name":"Org"}`, `{"name":"Org","meta":{"versionId":"2"}}`, true},
+		{`{"name":"Org"}`, `{"name":"Other"}`, false},
+		{`{"active":true}`, `{"active":"true"}`, false},
+		{`{"name":"Org"}`, `not json`, false},
+	}
+
+	for _, c := range cases {
+		if got := suppressEquivalentResourceBodyDiffs("resource", c.old, c.new, nil); got != c.equal {
+			t.Errorf("suppress(%s, %s) = %v, want %v", c.old, c.new, got, c.equal)
+		}
+	}
+}
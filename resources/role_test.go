@@ -1,3 +1,87 @@
 package resources
 
-// ... existing code ...
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"testing"
+
+	"github.com/flawless/terraform-provider-aidbox/internal/client"
+)
+
+func TestResourceAidboxRoleCreateGeneratesID(t *testing.T) {
+	cases := []struct {
+		strategy client.IDStrategy
+		method   string
+		pattern  string
+	}{
+		{client.IDStrategyPrefix, http.MethodPut, `^tf-[0-9a-f]{16}$`},
+		{client.IDStrategyUUID, http.MethodPut, `^[0-9a-f]{8}-[0-9a-f-]{27}$`},
+		{client.IDStrategyServer, http.MethodPost, `^server-1$`},
+	}
+
+	for _, tc := range cases {
+		t.Run(string(tc.strategy), func(t *testing.T) {
+			c, requests := newRecordingTestClient(t, false)
+			c.IDStrategy = tc.strategy
+			c.IDPrefix = "tf-"
+
+			r := ResourceAidboxRole()
+			d := r.TestResourceData()
+			d.Set("name", "admin")
+
+			if diags := r.CreateContext(context.Background(), d, c); diags.HasError() {
+				t.Fatalf("unexpected error: %v", diags)
+			}
+
+			if !regexp.MustCompile(tc.pattern).MatchString(d.Id()) {
+				t.Errorf("id %q does not match %s", d.Id(), tc.pattern)
+			}
+			if got := d.Get("resource_id"); got != d.Id() {
+				t.Errorf("expected resource_id %q, got %v", d.Id(), got)
+			}
+			var writes []string
+			for _, req := range *requests {
+				if req.Method != http.MethodGet {
+					writes = append(writes, req.Method)
+				}
+			}
+			if len(writes) != 1 || writes[0] != tc.method {
+				t.Errorf("expected the role to be created with %s, got %v", tc.method, writes)
+			}
+		})
+	}
+}
+
+func TestResourceAidboxRoleCreateExisting(t *testing.T) {
+	c, requests := newRecordingTestClient(t, false)
+	ctx := context.Background()
+	if err := c.CreateResource(ctx, "Role", "admin", `{"resourceType":"Role","id":"admin","name":"made by hand"}`); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	r := ResourceAidboxRole()
+	d := r.TestResourceData()
+	d.Set("resource_id", "admin")
+	d.Set("name", "admin")
+
+	diags := r.CreateContext(ctx, d, c)
+	if !diags.HasError() || diags[0].Summary != "Resource already exists" {
+		t.Fatalf("expected an already exists error, got %v", diags)
+	}
+	if d.Id() != "" {
+		t.Errorf("expected no id, got %q", d.Id())
+	}
+	if last := (*requests)[len(*requests)-1]; last.Method != http.MethodGet {
+		t.Errorf("the existing role must not be overwritten, got %s", last.Method)
+	}
+
+	// Adopting takes the existing role over
+	d.Set("adopt_existing", true)
+	if diags := r.CreateContext(ctx, d, c); diags.HasError() {
+		t.Fatalf("unexpected error: %v", diags)
+	}
+	if d.Id() != "admin" || d.Get("name") != "admin" {
+		t.Errorf("expected the role to be adopted, got id %q name %v", d.Id(), d.Get("name"))
+	}
+}
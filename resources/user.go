@@ -1,156 +1,236 @@
 package resources
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"regexp"
 
 	"github.com/flawless/terraform-provider-aidbox/internal/client"
+
 	"github.com/flawless/terraform-provider-aidbox/internal/resource"
+	"github.com/hashicorp/go-cty/cty"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
 )
 
+// emailPattern is a deliberately loose check; Aidbox validates addresses itself
+var emailPattern = regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s]+$`)
+
 func ResourceAidboxUser() *schema.Resource {
 	base := resource.NewBaseResource("User")
 
-	// Add user-specific schema fields
-	base.AddSchema("name", &schema.Schema{
-		Type:     schema.TypeList,
-		Required: true,
-		MaxItems: 1,
-		Elem: &schema.Resource{
-			Schema: map[string]*schema.Schema{
-				"given_name": {
-					Type:        schema.TypeString,
+	// Add user-specific fields
+	base.SetFields(
+		resource.Field{
+			Attribute: "name",
+			Type:      resource.FieldBlock,
+			Required:  true,
+			Fields: []resource.Field{
+				{
+					Attribute:   "given_name",
+					Path:        "givenName",
+					Type:        resource.FieldString,
 					Required:    true,
 					Description: "The given name of the user",
 				},
-				"family_name": {
-					Type:        schema.TypeString,
+				{
+					Attribute:   "family_name",
+					Path:        "familyName",
+					Type:        resource.FieldString,
 					Required:    true,
 					Description: "The family name of the user",
 				},
 			},
 		},
-	})
-
-	base.AddSchema("password", &schema.Schema{
-		Type:        schema.TypeString,
-		Optional:    true,
-		Sensitive:   true,
-		Description: "The password for the user",
-		DiffSuppressFunc: func(k, old, new string, d *schema.ResourceData) bool {
-			// Always suppress password differences since Aidbox hashes them
-			return true
+		resource.Field{
+			Attribute:    "email",
+			Type:         resource.FieldString,
+			ValidateFunc: validation.StringMatch(emailPattern, "must be a valid email address"),
+			Description:  "The email address of the user",
 		},
-	})
-
-	// Override the create function to handle the user-specific fields
-	base.SetCreateFunc(func(d *schema.ResourceData, m interface{}) error {
-		// Set the resource type
-		d.Set("resource_type", "User")
-
-		// Call the base create function to handle common fields
-		if err := resource.ResourceBaseCreate(d, m); err != nil {
-			return err
-		}
-
-		// Get the resource ID
-		resourceID := d.Id()
-
-		// Get the name from the schema
-		nameList := d.Get("name").([]interface{})
-		if len(nameList) == 0 {
-			return fmt.Errorf("name block is required")
-		}
-		nameMap := nameList[0].(map[string]interface{})
-
-		// Create a map for the user resource
-		userMap := map[string]interface{}{
-			"resourceType": "User",
-			"id":           resourceID,
-			"name": map[string]interface{}{
-				"givenName":  nameMap["given_name"],
-				"familyName": nameMap["family_name"],
+		resource.Field{
+			Attribute:   "user_name",
+			Path:        "userName",
+			Type:        resource.FieldString,
+			Description: "The login of the user, if different from the resource ID and email",
+		},
+		resource.Field{
+			Attribute:   "active",
+			Type:        resource.FieldBool,
+			Description: "Whether the user may log in",
+		},
+		resource.Field{
+			Attribute:   "phone_number",
+			Path:        "phoneNumber",
+			Type:        resource.FieldString,
+			Description: "The phone number of the user, e.g. for two-factor authentication",
+		},
+		resource.Field{
+			Attribute: "identifier",
+			Type:      resource.FieldBlockList,
+			Fields: []resource.Field{
+				{
+					Attribute:    "system",
+					Type:         resource.FieldString,
+					Required:     true,
+					ValidateFunc: validation.StringIsNotWhiteSpace,
+					Description:  "The namespace of the identifier value",
+				},
+				{
+					Attribute:    "value",
+					Type:         resource.FieldString,
+					Required:     true,
+					ValidateFunc: validation.StringIsNotWhiteSpace,
+					Description:  "The identifier value, unique within the system",
+				},
 			},
-		}
-
-		// Add password if provided
-		if password, ok := d.GetOk("password"); ok {
-			userMap["password"] = password.(string)
-		}
-
-		// Add extensions if provided
-		if extensions, ok := d.GetOk("extensions"); ok {
-			extensionsMap := extensions.(map[string]interface{})
-			for k, v := range extensionsMap {
-				userMap[k] = v
-			}
-		}
-
-		// Convert to JSON
-		userJSON, err := json.Marshal(userMap)
-		if err != nil {
-			return fmt.Errorf("failed to marshal user: %w", err)
-		}
-
-		// Update the resource with the user-specific fields
-		client := m.(*client.Client)
-		if err := client.UpdateResource("User", resourceID, string(userJSON)); err != nil {
-			return err
-		}
-
-		return resource.ResourceBaseRead(d, m)
+			Description: "Business identifiers of the user",
+		},
+		resource.Field{
+			Attribute: "telecom",
+			Type:      resource.FieldBlockList,
+			Fields: []resource.Field{
+				{
+					Attribute:    "system",
+					Type:         resource.FieldString,
+					Required:     true,
+					ValidateFunc: validation.StringInSlice([]string{"phone", "fax", "email", "pager", "url", "sms", "other"}, false),
+					Description:  "The kind of contact point (phone, fax, email, pager, url, sms, other)",
+				},
+				{
+					Attribute:    "value",
+					Type:         resource.FieldString,
+					Required:     true,
+					ValidateFunc: validation.StringIsNotWhiteSpace,
+					Description:  "The contact point details",
+				},
+				{
+					Attribute:    "use",
+					Type:         resource.FieldString,
+					ValidateFunc: validation.StringInSlice([]string{"home", "work", "temp", "old", "mobile"}, false),
+					Description:  "The purpose of the contact point (home, work, temp, old, mobile)",
+				},
+			},
+			Description: "Contact points of the user",
+		},
+		resource.Field{
+			Attribute:   "data",
+			Type:        resource.FieldJSON,
+			Description: "Free-form application data of the user as a JSON document, usually built with jsonencode()",
+		},
+		resource.Field{
+			Attribute: "link",
+			Type:      resource.FieldBlockList,
+			Fields: []resource.Field{
+				{
+					Attribute:    "resource_type",
+					Path:         "link.resourceType",
+					Type:         resource.FieldString,
+					Required:     true,
+					ValidateFunc: validation.StringInSlice([]string{"Patient", "Practitioner", "RelatedPerson", "Person"}, false),
+					Description:  "The type of the linked resource (Patient, Practitioner, RelatedPerson, Person)",
+				},
+				{
+					Attribute:   "id",
+					Path:        "link.id",
+					Type:        resource.FieldString,
+					Required:    true,
+					Description: "The ID of the linked resource",
+				},
+				{
+					Attribute:   "type",
+					Type:        resource.FieldString,
+					Description: "The kind of link, e.g. patient or practitioner",
+				},
+			},
+			Description: "Resources representing the user, such as their Patient or Practitioner",
+		},
+		resource.Field{
+			Attribute: "two_factor",
+			Path:      "twoFactor",
+			Type:      resource.FieldBlock,
+			Fields: []resource.Field{
+				{
+					Attribute:   "enabled",
+					Type:        resource.FieldBool,
+					Required:    true,
+					Description: "Whether two-factor authentication is required",
+				},
+				{
+					Attribute:    "transport",
+					Type:         resource.FieldString,
+					ValidateFunc: validation.StringInSlice([]string{"sms", "email", "webhook"}, false),
+					Description:  "How one-time codes are delivered (sms, email, webhook)",
+				},
+			},
+			Description: "Two-factor authentication settings",
+		},
+		// Aidbox only returns the password hashed, so it is never read back
+		resource.Field{
+			Attribute:   "password",
+			Type:        resource.FieldString,
+			Sensitive:   true,
+			WriteOnly:   true,
+			Deprecated:  "password is stored in the Terraform state. Use password_wo and password_version instead.",
+			Description: "The password for the user. It is stored in state; prefer password_wo",
+		},
+	)
+
+	base.AddSchema("password_wo", &schema.Schema{
+		Type:          schema.TypeString,
+		Optional:      true,
+		Sensitive:     true,
+		WriteOnly:     true,
+		ConflictsWith: []string{"password"},
+		Description:   "The password for the user. It is never stored in state and only sent on create and when password_version changes. Requires Terraform 1.11 or later",
 	})
 
-	// Override the update function to handle the user-specific fields
-	base.SetUpdateFunc(func(d *schema.ResourceData, m interface{}) error {
-		// Get the resource ID
-		resourceID := d.Id()
-
-		// Get the name from the schema
-		nameList := d.Get("name").([]interface{})
-		if len(nameList) == 0 {
-			return fmt.Errorf("name block is required")
-		}
-		nameMap := nameList[0].(map[string]interface{})
-
-		// Create a map for the user resource
-		userMap := map[string]interface{}{
-			"resourceType": "User",
-			"id":           resourceID,
-			"name": map[string]interface{}{
-				"givenName":  nameMap["given_name"],
-				"familyName": nameMap["family_name"],
-			},
-		}
+	base.AddSchema("password_version", &schema.Schema{
+		Type:         schema.TypeInt,
+		Optional:     true,
+		RequiredWith: []string{"password_wo"},
+		Description:  "Change this value to send password_wo to Aidbox again, e.g. to rotate the password",
+	})
 
-		// Add password if provided
-		if password, ok := d.GetOk("password"); ok {
-			userMap["password"] = password.(string)
-		}
+	base.SetExpandFunc(expandUserPassword)
 
-		// Add extensions if provided
-		if extensions, ok := d.GetOk("extensions"); ok {
-			extensionsMap := extensions.(map[string]interface{})
-			for k, v := range extensionsMap {
-				userMap[k] = v
-			}
-		}
+	return base.ToResource()
+}
 
-		// Convert to JSON
-		userJSON, err := json.Marshal(userMap)
-		if err != nil {
-			return fmt.Errorf("failed to marshal user: %w", err)
+// expandUserPassword sets the password from password_wo on create and when
+// password_version changes. Updates replace the whole user, so otherwise the
+// stored password hash is sent back to keep the current password.
+func expandUserPassword(ctx context.Context, d *schema.ResourceData, m interface{}, resourceMap map[string]interface{}) error {
+	// The deprecated password attribute is sent on every request
+	if _, ok := resourceMap["password"]; ok {
+		return nil
+	}
+
+	if (d.Id() == "" || d.HasChange("password_version")) && !d.GetRawConfig().IsNull() {
+		password, diags := d.GetRawConfigAt(cty.GetAttrPath("password_wo"))
+		if diags.HasError() {
+			return fmt.Errorf("failed to read password_wo")
 		}
-
-		// Update the resource with the user-specific fields
-		client := m.(*client.Client)
-		if err := client.UpdateResource("User", resourceID, string(userJSON)); err != nil {
-			return err
+		if password.IsKnown() && !password.IsNull() {
+			resourceMap["password"] = password.AsString()
+			return nil
 		}
-
-		return resource.ResourceBaseRead(d, m)
-	})
-
-	return base.ToResource()
+	}
+	if d.Id() == "" {
+		return nil
+	}
+
+	current, err := m.(*client.Client).GetResource(ctx, "User", d.Id())
+	if err != nil {
+		return err
+	}
+	var currentMap map[string]interface{}
+	if err := json.Unmarshal([]byte(current), &currentMap); err != nil {
+		return fmt.Errorf("failed to parse resource JSON: %w", err)
+	}
+	if hash, ok := currentMap["password"]; ok {
+		resourceMap["password"] = hash
+	}
+	return nil
 }
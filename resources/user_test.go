@@ -1,7 +1,19 @@
 package resources
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+
+	"github.com/flawless/terraform-provider-aidbox/internal/client"
+	"github.com/flawless/terraform-provider-aidbox/internal/resource"
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
 )
 
 func TestResourceAidboxUser(t *testing.T) {
@@ -28,7 +40,7 @@ func TestResourceAidboxUser(t *testing.T) {
 	}
 
 	// Test optional fields
-	optionalFields := []string{"password", "resource_id", "extensions"}
+	optionalFields := []string{"password", "resource_id", "extra_json"}
 	for _, field := range optionalFields {
 		if schema[field] == nil {
 			t.Errorf("optional field %s is missing", field)
@@ -48,4 +60,307 @@ func TestResourceAidboxUser(t *testing.T) {
 			t.Errorf("field %s should be computed", field)
 		}
 	}
+
+	// Test import support
+	if resource.Importer == nil {
+		t.Error("resource should be importable")
+	}
+
+	// Test timeouts
+	if resource.Timeouts == nil || resource.Timeouts.Create == nil || resource.Timeouts.Delete == nil {
+		t.Error("timeouts should be configurable")
+	}
+}
+
+func TestResourceAidboxUserRead(t *testing.T) {
+	c := newTestClient(t, map[string]string{
+		"/User/jane": `{
+			"resourceType": "User",
+			"id": "jane",
+			"name": {"givenName": "Jane", "familyName": "Roe"},
+			"password": "$2a$10$hashedpassword",
+			"email": "jane@example.com",
+			"userName": "jroe",
+			"active": false,
+			"identifier": [{"system": "https://hospital.example.com/staff", "value": "4711"}],
+			"telecom": [{"system": "phone", "value": "+1 555 0100", "use": "work"}],
+			"data": {"department": "cardiology", "floors": [2, 3]},
+			"link": [{"link": {"resourceType": "Practitioner", "id": "pr-1"}, "type": "practitioner"}],
+			"twoFactor": {"enabled": true, "transport": "sms"},
+			"theme": "dark",
+			"meta": {"versionId": "7", "lastUpdated": "2024-03-12T10:21:44Z", "createdAt": "2024-03-11T08:02:13Z"}
+		}`,
+	})
+
+	r := ResourceAidboxUser()
+	d := r.TestResourceData()
+	d.SetId("jane")
+	d.Set("name", []interface{}{map[string]interface{}{"given_name": "Jane", "family_name": "Doe"}})
+
+	if diags := r.ReadContext(context.Background(), d, c); diags.HasError() {
+		t.Fatalf("unexpected error: %v", diags)
+	}
+
+	// The family name changed outside Terraform
+	if got := d.Get("name.0.family_name"); got != "Roe" {
+		t.Errorf("expected family_name Roe, got %v", got)
+	}
+	if got := d.Get("meta.0.version_id"); got != "7" {
+		t.Errorf("expected version_id 7, got %v", got)
+	}
+	if got := d.Get("resource_id"); got != "jane" {
+		t.Errorf("expected resource_id jane, got %v", got)
+	}
+
+	expected := map[string]interface{}{
+		"email":                  "jane@example.com",
+		"user_name":              "jroe",
+		"active":                 false,
+		"identifier.0.system":    "https://hospital.example.com/staff",
+		"identifier.0.value":     "4711",
+		"telecom.0.use":          "work",
+		"data":                   `{"department":"cardiology","floors":[2,3]}`,
+		"link.0.resource_type":   "Practitioner",
+		"link.0.id":              "pr-1",
+		"link.0.type":            "practitioner",
+		"two_factor.0.enabled":   true,
+		"two_factor.0.transport": "sms",
+	}
+	for k, want := range expected {
+		if got := d.Get(k); got != want {
+			t.Errorf("%s: expected %v, got %v", k, want, got)
+		}
+	}
+
+	// Typed fields never leak into extra_json
+	var extra map[string]interface{}
+	if err := json.Unmarshal([]byte(d.Get("extra_json").(string)), &extra); err != nil {
+		t.Fatalf("failed to parse extra_json: %v", err)
+	}
+	if len(extra) != 1 || extra["theme"] != "dark" {
+		t.Errorf("expected only theme in extra_json, got %v", extra)
+	}
+}
+
+func TestResourceAidboxUserReadDeleted(t *testing.T) {
+	c := newTestClient(t, map[string]string{})
+
+	r := ResourceAidboxUser()
+	d := r.TestResourceData()
+	d.SetId("gone")
+
+	if diags := r.ReadContext(context.Background(), d, c); diags.HasError() {
+		t.Fatalf("unexpected error: %v", diags)
+	}
+	if d.Id() != "" {
+		t.Error("a deleted user should be removed from state")
+	}
+}
+
+// newTestClient starts an Aidbox stand-in serving the given resource bodies
+// by path; any other path answers 404
+func newTestClient(t *testing.T, resources map[string]string) *client.Client {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/auth/token" {
+			fmt.Fprint(w, `{"access_token":"token","expires_in":3600}`)
+			return
+		}
+		body, ok := resources[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"resourceType":"OperationOutcome","issue":[{"severity":"fatal","code":"not-found"}]}`)
+			return
+		}
+		fmt.Fprint(w, body)
+	}))
+	t.Cleanup(server.Close)
+
+	return client.NewClient(&client.Config{URL: server.URL, ClientID: "id", ClientSecret: "secret"})
+}
+
+// testRequest is a request received by the fake Aidbox of newRecordingTestClient
+type testRequest struct {
+	Method string
+	Path   string
+	Body   string
+}
+
+// newRecordingTestClient returns a client for an in-memory Aidbox that stores
+// written resources and serves them back. failReads makes every GET of a
+// stored resource fail.
+func newRecordingTestClient(t *testing.T, failReads bool) (*client.Client, *[]testRequest) {
+	t.Helper()
+
+	var requests []testRequest
+	stored := make(map[string]string)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/auth/token" {
+			fmt.Fprint(w, `{"access_token":"token","expires_in":3600}`)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		requests = append(requests, testRequest{Method: r.Method, Path: r.URL.Path, Body: string(body)})
+
+		switch r.Method {
+		case http.MethodPut:
+			stored[r.URL.Path] = string(body)
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprint(w, string(body))
+		case http.MethodPost:
+			// Aidbox assigns the ID
+			var resource map[string]interface{}
+			json.Unmarshal(body, &resource)
+			resource["id"] = fmt.Sprintf("server-%d", len(stored)+1)
+			created, _ := json.Marshal(resource)
+			stored[r.URL.Path+"/"+resource["id"].(string)] = string(created)
+			w.WriteHeader(http.StatusCreated)
+			w.Write(created)
+		case http.MethodGet:
+			body, ok := stored[r.URL.Path]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				fmt.Fprint(w, `{"resourceType":"OperationOutcome","issue":[{"severity":"fatal","code":"not-found"}]}`)
+				return
+			}
+			if failReads {
+				w.WriteHeader(http.StatusInternalServerError)
+				fmt.Fprint(w, `{"resourceType":"OperationOutcome","issue":[{"severity":"fatal","code":"exception"}]}`)
+				return
+			}
+			fmt.Fprint(w, body)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return client.NewClient(&client.Config{URL: server.URL, ClientID: "id", ClientSecret: "secret"}), &requests
+}
+
+func TestResourceAidboxUserCreate(t *testing.T) {
+	c, requests := newRecordingTestClient(t, false)
+
+	r := ResourceAidboxUser()
+	d := r.TestResourceData()
+	d.Set("resource_id", "jane")
+	d.Set("password", "secret")
+	d.Set("name", []interface{}{map[string]interface{}{"given_name": "Jane", "family_name": "Doe"}})
+
+	if diags := r.CreateContext(context.Background(), d, c); diags.HasError() {
+		t.Fatalf("unexpected error: %v", diags)
+	}
+
+	// The user is written once, complete with its name and password
+	var writes []testRequest
+	for _, req := range *requests {
+		if req.Method != http.MethodGet {
+			writes = append(writes, req)
+		}
+	}
+	if len(writes) != 1 {
+		t.Fatalf("expected a single write, got %v", writes)
+	}
+	expected := `{"resourceType":"User","id":"jane","password":"secret","name":{"givenName":"Jane","familyName":"Doe"}}`
+	if body := writes[0].Body; !resource.JSONEqual(body, expected) {
+		t.Errorf("unexpected body %s", body)
+	}
+}
+
+func TestResourceAidboxUserValidation(t *testing.T) {
+	name := []interface{}{map[string]interface{}{"given_name": "Jane", "family_name": "Doe"}}
+	cases := []struct {
+		name   string
+		config map[string]interface{}
+		valid  bool
+	}{
+		{"valid", map[string]interface{}{"name": name, "email": "jane@example.com"}, true},
+		{"invalid email", map[string]interface{}{"name": name, "email": "jane.example.com"}, false},
+		{"identifier without system", map[string]interface{}{
+			"name":       name,
+			"identifier": []interface{}{map[string]interface{}{"value": "4711"}},
+		}, false},
+		{"unknown link type", map[string]interface{}{
+			"name": name,
+			"link": []interface{}{map[string]interface{}{"resource_type": "Organization", "id": "org-1"}},
+		}, false},
+	}
+
+	r := ResourceAidboxUser()
+	for _, tc := range cases {
+		diags := r.Validate(terraform.NewResourceConfigRaw(tc.config))
+		if diags.HasError() == tc.valid {
+			t.Errorf("%s: expected valid=%v, got %v", tc.name, tc.valid, diags)
+		}
+	}
+}
+
+func TestResourceAidboxUserPasswordWriteOnly(t *testing.T) {
+	c, requests := newRecordingTestClient(t, false)
+	ctx := context.Background()
+	r := ResourceAidboxUser()
+
+	apply := func(state *terraform.InstanceState, version int) *terraform.InstanceState {
+		t.Helper()
+		config := map[string]interface{}{
+			"resource_id":      "jane",
+			"name":             []interface{}{map[string]interface{}{"given_name": "Jane", "family_name": "Doe"}},
+			"password_version": version,
+		}
+		diff, err := r.Diff(ctx, state, terraform.NewResourceConfigRaw(config), c)
+		if err != nil {
+			t.Fatalf("unexpected diff error: %v", err)
+		}
+		if diff == nil {
+			return state
+		}
+		// Terraform passes write-only values only through the raw configuration
+		diff.RawConfig = cty.ObjectVal(map[string]cty.Value{
+			"password_wo":      cty.StringVal(fmt.Sprintf("secret-%d", version)),
+			"password_version": cty.NumberIntVal(int64(version)),
+		})
+		newState, diags := r.Apply(ctx, state, diff, c)
+		if diags.HasError() {
+			t.Fatalf("unexpected apply error: %v", diags)
+		}
+		return newState
+	}
+	lastPassword := func() interface{} {
+		t.Helper()
+		var body map[string]interface{}
+		for _, req := range *requests {
+			if req.Method == http.MethodPut {
+				body = nil
+				json.Unmarshal([]byte(req.Body), &body)
+			}
+		}
+		return body["password"]
+	}
+
+	state := apply(nil, 1)
+	if got := lastPassword(); got != "secret-1" {
+		t.Fatalf("expected the password to be sent on create, got %v", got)
+	}
+	for k, v := range state.Attributes {
+		if strings.Contains(v, "secret-") {
+			t.Errorf("password persisted in state as %s", k)
+		}
+	}
+
+	// Bumping the version rotates the password
+	state = apply(state, 2)
+	if got := lastPassword(); got != "secret-2" {
+		t.Fatalf("expected the new password to be sent, got %v", got)
+	}
+
+	// Other updates keep the stored password
+	d := r.Data(state)
+	d.Set("email", "jane@example.com")
+	if diags := r.UpdateContext(ctx, d, c); diags.HasError() {
+		t.Fatalf("unexpected error: %v", diags)
+	}
+	if got := lastPassword(); got != "secret-2" {
+		t.Errorf("expected the stored password to be kept, got %v", got)
+	}
 }